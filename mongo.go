@@ -2,26 +2,98 @@ package xk6_mongo
 
 import (
 	"context"
-	"fmt"
 	"github.com/ganinw13120/xk6-mongo/mongo"
+	"go.k6.io/k6/js/common"
 	k6modules "go.k6.io/k6/js/modules"
 )
 
 func init() {
-	k6modules.Register("k6/x/mongo", new(Mongo))
+	k6modules.Register("k6/x/mongo", new(RootModule))
 }
 
-type Mongo struct{}
+type RootModule struct{}
 
-func (*Mongo) NewClient(uri, database, collection string, pipeline interface{}) interface{} {
-	client, err := mongo.NewMongoDBConnection(context.TODO(), uri)
+type ModuleInstance struct {
+	mongo *Mongo
+}
+
+type Mongo struct {
+	vu      k6modules.VU
+	metrics *mongo.Metrics
+}
+
+func (*RootModule) NewModuleInstance(vu k6modules.VU) k6modules.Instance {
+	metrics, err := mongo.RegisterMetrics(vu.InitEnv().Registry)
 	if err != nil {
-		fmt.Println(err)
-		return err
+		common.Throw(vu.Runtime(), err)
+	}
+	return &ModuleInstance{
+		mongo: &Mongo{vu: vu, metrics: metrics},
+	}
+}
+
+func (mi *ModuleInstance) Exports() k6modules.Exports {
+	return k6modules.Exports{Default: mi.mongo}
+}
+
+// NewClient returns a handle to a collection. pipeline is unused and kept for compatibility, so
+// scripts pass null before the client options.
+func (m *Mongo) NewClient(uri, database, collection string, pipeline interface{}, opts mongo.ClientOptions) (interface{}, error) {
+	reporter := m.reporter()
+	client, err := mongo.NewMongoDBConnection(context.TODO(), uri, reporter, opts)
+	if err != nil {
+		return nil, err
 	}
 
 	col := client.Database(database).Collection(collection)
-	db := mongo.NewMongoDB(client, col)
+	db := mongo.NewMongoDB(client, col, reporter)
+
+	return db, nil
+}
+
+func (m *Mongo) NewDatabase(uri, database string, opts mongo.ClientOptions) (interface{}, error) {
+	reporter := m.reporter()
+	client, err := mongo.NewMongoDBConnection(context.TODO(), uri, reporter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return mongo.NewDatabase(client, database, reporter, m.vu), nil
+}
+
+// Generate returns count documents built from template, ready to pass to insertMany.
+func (*Mongo) Generate(template map[string]interface{}, count int, opts mongo.GenerateOptions) ([]interface{}, error) {
+	return mongo.Generate(template, count, opts)
+}
+
+// Replay reissues a workload recorded with recordWorkload against a collection or database
+// handle.
+func (m *Mongo) Replay(target interface{}, workload string, opts mongo.ReplayOptions) (*mongo.ReplaySummary, error) {
+	return mongo.Replay(m.vu, target, workload, opts)
+}
+
+// Fingerprint returns the query shape of a filter: its keys sorted and its values replaced by
+// "?".
+func (*Mongo) Fingerprint(filter interface{}) string {
+	return mongo.Fingerprint(filter)
+}
+
+// KeyChooser creates a chooser of record keys following a key distribution.
+func (*Mongo) KeyChooser(opts mongo.KeyChooserOptions) (interface{}, error) {
+	return mongo.NewKeyChooser(opts)
+}
+
+// Sequence returns the named sequence of unique integers, shared by every VU.
+func (*Mongo) Sequence(name string, opts mongo.SequenceOptions) (interface{}, error) {
+	return mongo.NewSequence(name, opts)
+}
+
+// Churn connects a fresh single-connection client, runs one operation and disconnects, as a
+// short-lived client would.
+func (m *Mongo) Churn(uri string, opts mongo.ChurnOptions) (*mongo.ChurnResult, error) {
+	return mongo.Churn(m.reporter(), uri, opts)
+}
 
-	return db
+func (m *Mongo) reporter() *mongo.Reporter {
+	return mongo.NewReporter(m.vu, m.metrics)
 }
@@ -0,0 +1,141 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	churnPing      = "ping"
+	churnFindOne   = "findOne"
+	churnInsertOne = "insertOne"
+	churnCommand   = "command"
+
+	defaultChurnTimeout = 30 * time.Second
+)
+
+type ChurnOptions struct {
+	Database   string `js:"database"`
+	Collection string `js:"collection"`
+	// Operation is "ping" (the default), "findOne" with Filter, "insertOne" with Document or
+	// "command" with Command, run against Database.
+	Operation string      `js:"operation"`
+	Filter    interface{} `js:"filter"`
+	Document  interface{} `js:"document"`
+	Command   goja.Value  `js:"command"`
+	// TimeoutMs bounds the whole connect, operation and disconnect cycle.
+	TimeoutMs int64  `js:"timeoutMs"`
+	Proxy     string `js:"proxy"`
+}
+
+type ChurnResult struct {
+	ConnectMs float64 `js:"connectMs"`
+	FirstOpMs float64 `js:"firstOpMs"`
+	CloseMs   float64 `js:"closeMs"`
+}
+
+// Churn behaves like a short-lived client, such as a serverless function: it connects with a
+// single-connection pool, waits for an authenticated connection, runs one operation and
+// disconnects. The three phases are reported as mongo_churn_connect_duration,
+// mongo_churn_first_op_duration and mongo_churn_close_duration, and the client is disconnected
+// whatever the outcome so that no connections or monitoring goroutines outlive the call.
+func Churn(reporter *Reporter, uri string, opts ChurnOptions) (*ChurnResult, error) {
+	run, err := churnOperation(opts)
+	if err != nil {
+		return nil, err
+	}
+	sink := reporter.sink()
+	if sink == nil {
+		return nil, errors.New("churn can only run from VU code, not the init context")
+	}
+	timeout := defaultChurnTimeout
+	if opts.TimeoutMs > 0 {
+		timeout = time.Duration(opts.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(sink.ctx, timeout)
+	defer cancel()
+	if opts.Operation == "" {
+		opts.Operation = churnPing
+	}
+	tags := map[string]string{"op": opts.Operation}
+	result := &ChurnResult{}
+
+	began := time.Now()
+	client, err := NewMongoDBConnection(ctx, uri, reporter, ClientOptions{Proxy: opts.Proxy, MaxPoolSize: 1})
+	if err == nil {
+		err = client.Ping(ctx, nil)
+	}
+	result.ConnectMs = durationMs(time.Since(began))
+	reporter.churnPhase(reporter.metrics.ChurnConnectDuration, result.ConnectMs, tags, err)
+	if err != nil {
+		if client != nil {
+			_ = client.Disconnect(context.Background())
+		}
+		reporter.flushConnections()
+		return result, err
+	}
+
+	began = time.Now()
+	opErr := run(ctx, client.Database(opts.Database))
+	result.FirstOpMs = durationMs(time.Since(began))
+	reporter.churnPhase(reporter.metrics.ChurnFirstOpDuration, result.FirstOpMs, tags, opErr)
+
+	// Disconnecting must not be cut short by the test ending, or sockets would be left open.
+	began = time.Now()
+	closeErr := client.Disconnect(context.Background())
+	result.CloseMs = durationMs(time.Since(began))
+	reporter.churnPhase(reporter.metrics.ChurnCloseDuration, result.CloseMs, tags, closeErr)
+	reporter.flushConnections()
+
+	if opErr != nil {
+		return result, opErr
+	}
+	return result, closeErr
+}
+
+func churnOperation(opts ChurnOptions) (func(context.Context, *mongo.Database) error, error) {
+	switch opts.Operation {
+	case "", churnPing:
+		return func(ctx context.Context, db *mongo.Database) error {
+			return db.Client().Ping(ctx, nil)
+		}, nil
+	case churnFindOne:
+		if opts.Collection == "" {
+			return nil, errors.New("churn findOne requires a collection")
+		}
+		filter := opts.Filter
+		if filter == nil {
+			filter = bson.D{}
+		}
+		return func(ctx context.Context, db *mongo.Database) error {
+			err := db.Collection(opts.Collection).FindOne(ctx, filter).Err()
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return nil
+			}
+			return err
+		}, nil
+	case churnInsertOne:
+		if opts.Collection == "" || opts.Document == nil {
+			return nil, errors.New("churn insertOne requires a collection and a document")
+		}
+		return func(ctx context.Context, db *mongo.Database) error {
+			_, err := db.Collection(opts.Collection).InsertOne(ctx, opts.Document)
+			return err
+		}, nil
+	case churnCommand:
+		if opts.Command == nil || goja.IsUndefined(opts.Command) {
+			return nil, errors.New("churn command requires a command")
+		}
+		return func(ctx context.Context, db *mongo.Database) error {
+			return db.RunCommand(ctx, toDocument(opts.Command)).Err()
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown churn operation %s", opts.Operation)
+	}
+}
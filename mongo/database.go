@@ -0,0 +1,189 @@
+package mongo
+
+import (
+	"context"
+	"time"
+
+	"github.com/dop251/goja"
+	k6modules "go.k6.io/k6/js/modules"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+type RunCommandOptions struct {
+	ReadPreference string `js:"readPreference"`
+}
+
+type TimeSeriesOptions struct {
+	TimeField             string `js:"timeField"`
+	MetaField             string `js:"metaField"`
+	Granularity           string `js:"granularity"`
+	BucketMaxSpanSeconds  int64  `js:"bucketMaxSpanSeconds"`
+	BucketRoundingSeconds int64  `js:"bucketRoundingSeconds"`
+}
+
+type CreateCollectionOptions struct {
+	Capped             bool               `js:"capped"`
+	Size               int64              `js:"size"`
+	Max                int64              `js:"max"`
+	Validator          interface{}        `js:"validator"`
+	ValidationLevel    string             `js:"validationLevel"`
+	ValidationAction   string             `js:"validationAction"`
+	TimeSeries         *TimeSeriesOptions `js:"timeseries"`
+	ExpireAfterSeconds int64              `js:"expireAfterSeconds"`
+	ClusteredIndex     interface{}        `js:"clusteredIndex"`
+}
+
+type database struct {
+	MongoClient *mongo.Client
+	Database    *mongo.Database
+	Reporter    *Reporter
+	vu          k6modules.VU
+}
+
+func NewDatabase(mongoClient *mongo.Client, name string, reporter *Reporter, vu k6modules.VU) *database {
+	return &database{
+		mongoClient,
+		mongoClient.Database(name),
+		reporter,
+		vu,
+	}
+}
+
+// Collection returns a handle to the named collection that shares this database's client.
+func (d *database) Collection(name string) *mongodb {
+	return NewMongoDB(d.MongoClient, d.Database.Collection(name), d.Reporter)
+}
+
+// RunCommand executes an arbitrary database command such as serverStatus, collStats or dbStats.
+// The command is taken as a JS object so its key order, and therefore the command name, is kept.
+func (d *database) RunCommand(command goja.Value, opts RunCommandOptions) (bson.M, error) {
+	ctx := context.TODO()
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	runOpts := options.RunCmd()
+	if opts.ReadPreference != "" {
+		rp, err := readPreference(opts.ReadPreference)
+		if err != nil {
+			return nil, err
+		}
+		runOpts.SetReadPreference(rp)
+	}
+	var result bson.M
+	err := d.Database.RunCommand(ctx, toDocument(command), runOpts).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (d *database) CreateCollection(name string, opts CreateCollectionOptions) error {
+	ctx := context.TODO()
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	return d.Database.CreateCollection(ctx, name, opts.toDriver())
+}
+
+// CreateTimeSeriesCollection creates a time-series collection. expireAfterSeconds enables
+// automatic removal of old measurements when it is greater than zero.
+func (d *database) CreateTimeSeriesCollection(name string, opts TimeSeriesOptions, expireAfterSeconds int64) error {
+	return d.CreateCollection(name, CreateCollectionOptions{
+		TimeSeries:         &opts,
+		ExpireAfterSeconds: expireAfterSeconds,
+	})
+}
+
+func (d *database) DropCollection(name string) error {
+	ctx := context.TODO()
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	return d.Database.Collection(name).Drop(ctx)
+}
+
+// RenameCollection renames a collection within this database. renameCollection must be run
+// against the admin database, which is why the command is not issued on d.Database.
+func (d *database) RenameCollection(from, to string, dropTarget bool) error {
+	ctx := context.TODO()
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	command := bson.D{
+		{Key: "renameCollection", Value: d.Database.Name() + "." + from},
+		{Key: "to", Value: d.Database.Name() + "." + to},
+		{Key: "dropTarget", Value: dropTarget},
+	}
+	return d.MongoClient.Database("admin").RunCommand(ctx, command).Err()
+}
+
+func (d *database) ListCollectionNames(filter interface{}) ([]string, error) {
+	ctx := context.TODO()
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	if filter == nil {
+		filter = bson.D{}
+	}
+	return d.Database.ListCollectionNames(ctx, filter)
+}
+
+func (d *database) Drop() error {
+	ctx := context.TODO()
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	return d.Database.Drop(ctx)
+}
+
+func (o CreateCollectionOptions) toDriver() *options.CreateCollectionOptions {
+	opts := options.CreateCollection()
+	if o.Capped {
+		opts.SetCapped(true)
+		opts.SetSizeInBytes(o.Size)
+		if o.Max > 0 {
+			opts.SetMaxDocuments(o.Max)
+		}
+	}
+	if o.Validator != nil {
+		opts.SetValidator(o.Validator)
+	}
+	if o.ValidationLevel != "" {
+		opts.SetValidationLevel(o.ValidationLevel)
+	}
+	if o.ValidationAction != "" {
+		opts.SetValidationAction(o.ValidationAction)
+	}
+	if o.TimeSeries != nil {
+		opts.SetTimeSeriesOptions(o.TimeSeries.toDriver())
+	}
+	if o.ExpireAfterSeconds > 0 {
+		opts.SetExpireAfterSeconds(o.ExpireAfterSeconds)
+	}
+	if o.ClusteredIndex != nil {
+		opts.SetClusteredIndex(o.ClusteredIndex)
+	}
+	return opts
+}
+
+func (o TimeSeriesOptions) toDriver() *options.TimeSeriesOptions {
+	opts := options.TimeSeries().SetTimeField(o.TimeField)
+	if o.MetaField != "" {
+		opts.SetMetaField(o.MetaField)
+	}
+	if o.Granularity != "" {
+		opts.SetGranularity(o.Granularity)
+	}
+	if o.BucketMaxSpanSeconds > 0 {
+		opts.SetBucketMaxSpan(time.Duration(o.BucketMaxSpanSeconds) * time.Second)
+	}
+	if o.BucketRoundingSeconds > 0 {
+		opts.SetBucketRounding(time.Duration(o.BucketRoundingSeconds) * time.Second)
+	}
+	return opts
+}
+
+func readPreference(mode string, opts ...readpref.Option) (*readpref.ReadPref, error) {
+	m, err := readpref.ModeFromString(mode)
+	if err != nil {
+		return nil, err
+	}
+	return readpref.New(m, opts...)
+}
@@ -0,0 +1,209 @@
+package mongo
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext"
+	"go.k6.io/k6/metrics"
+)
+
+// traffic counts the bytes a VU's client exchanges with each host. Connections are read and
+// written from driver goroutines, so the counts are only turned into data_sent and data_received
+// samples when the VU reports an operation.
+type traffic struct {
+	mu    sync.Mutex
+	hosts map[string]*hostTraffic
+}
+
+type hostTraffic struct {
+	sent, received int64
+}
+
+func newTraffic() *traffic {
+	return &traffic{hosts: make(map[string]*hostTraffic)}
+}
+
+func (t *traffic) host(address string) *hostTraffic {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.hosts[address]
+	if !ok {
+		h = &hostTraffic{}
+		t.hosts[address] = h
+	}
+	return h
+}
+
+// drain returns the bytes counted per host since the previous call.
+func (t *traffic) drain(fn func(host string, sent, received int64)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for address, h := range t.hosts {
+		sent, received := atomic.SwapInt64(&h.sent, 0), atomic.SwapInt64(&h.received, 0)
+		if sent > 0 || received > 0 {
+			fn(address, sent, received)
+		}
+	}
+}
+
+// dialer opens the driver's connections through the VU's k6 dialer, so that the hosts,
+// blacklistIPs, blockHostnames and local IP options apply to them, and counts their traffic.
+// The k6 dialer only exists once the VU runs code, while the driver starts dialing as soon as
+// the client is created in the init context, so dials wait until the dialer is bound.
+type dialer struct {
+	base    lib.DialContexter
+	bound   chan struct{}
+	once    sync.Once
+	traffic *traffic
+	// proxy, when set, tunnels every connection through a SOCKS5 or HTTP CONNECT proxy.
+	proxy *url.URL
+	// tls is the client's TLS configuration when the dialer performs the TLS handshake itself, in
+	// place of the driver, so that it can be timed.
+	tls *tls.Config
+	// encrypted is set when the driver performs TLS itself, which leaves the dialer unable to
+	// observe the MongoDB handshake.
+	encrypted bool
+	metrics   *Metrics
+
+	mu sync.Mutex
+	// timings holds the connection measurements taken on driver goroutines until the VU reports
+	// them.
+	timings []connectionTiming
+}
+
+type connectionTiming struct {
+	metric  *metrics.Metric
+	elapsed time.Duration
+	tags    map[string]string
+}
+
+func newDialer(m *Metrics) *dialer {
+	return &dialer{
+		bound:   make(chan struct{}),
+		traffic: newTraffic(),
+		metrics: m,
+	}
+}
+
+// bind sets the dialer connections are opened with. Only the first call has any effect.
+func (d *dialer) bind(base lib.DialContexter) {
+	d.once.Do(func() {
+		d.base = base
+		close(d.bound)
+	})
+}
+
+func (d *dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if d.proxy != nil {
+		conn, err = d.dialProxy(ctx, network, address)
+	} else {
+		conn, err = d.dialDirect(ctx, network, address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn = &countingConn{conn, d.traffic.host(address)}
+	if d.encrypted {
+		return conn, nil
+	}
+	if d.tls != nil {
+		if conn, err = d.handshakeTLS(ctx, conn, address); err != nil {
+			return nil, err
+		}
+	}
+	return &handshakeConn{Conn: conn, dialer: d, host: address}, nil
+}
+
+func (d *dialer) handshakeTLS(ctx context.Context, conn net.Conn, address string) (net.Conn, error) {
+	cfg := d.tls.Clone()
+	if cfg.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		cfg.ServerName = host
+	}
+	tlsConn := tls.Client(conn, cfg)
+	began := time.Now()
+	err := tlsConn.HandshakeContext(ctx)
+	d.record(func(m *Metrics) *metrics.Metric { return m.TLSHandshakeDuration }, time.Since(began), map[string]string{
+		"host":   address,
+		"status": status(err),
+	})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// dialDirect opens a connection with the bound dialer.
+func (d *dialer) dialDirect(ctx context.Context, network, address string) (net.Conn, error) {
+	select {
+	case <-d.bound:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	began := time.Now()
+	conn, err := d.base.DialContext(ctx, network, address)
+	d.record(func(m *Metrics) *metrics.Metric { return m.ConnectDuration }, time.Since(began), map[string]string{
+		"host":   address,
+		"status": status(err),
+	})
+	if err != nil {
+		return nil, err
+	}
+	// k6 counts the traffic of its connections into data_sent and data_received at the end of
+	// each iteration. The traffic is counted by the dialer instead, per host.
+	if c, ok := conn.(*netext.Conn); ok {
+		conn = c.Conn
+	}
+	return conn, nil
+}
+
+// record keeps a connection measurement until the VU reports it. Measurements are dropped when
+// the dialer has no metrics to report them to.
+func (d *dialer) record(metric func(*Metrics) *metrics.Metric, elapsed time.Duration, tags map[string]string) {
+	if d.metrics == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.timings = append(d.timings, connectionTiming{metric(d.metrics), elapsed, tags})
+}
+
+func (d *dialer) drainTimings() []connectionTiming {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	timings := d.timings
+	d.timings = nil
+	return timings
+}
+
+type countingConn struct {
+	net.Conn
+	traffic *hostTraffic
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(&c.traffic.received, int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddInt64(&c.traffic.sent, int64(n))
+	return n, err
+}
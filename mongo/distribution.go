@@ -0,0 +1,295 @@
+package mongo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	distributionUniform          = "uniform"
+	distributionZipfian          = "zipfian"
+	distributionScrambledZipfian = "scrambledZipfian"
+	distributionHotspot          = "hotspot"
+	distributionLatest           = "latest"
+	distributionSequential       = "sequential"
+
+	// zipfianConstant is the skew used by YCSB: roughly 20% of the keys get 80% of the accesses.
+	zipfianConstant = 0.99
+
+	defaultHotsetFraction = 0.2
+	defaultHotOpnFraction = 0.8
+)
+
+// keyspace tracks how many records a collection holds so that every VU inserting into it
+// allocates distinct keys and every VU choosing keys sees the records inserted so far. Like YCSB's
+// acknowledged counter, an allocated key only becomes visible once its insert, and the inserts of
+// every key allocated before it, have completed.
+type keyspace struct {
+	// count is the number of visible records.
+	count int64
+	// next is the index the next insert is allocated.
+	next int64
+	// cursor is the next index handed out by the sequential distribution.
+	cursor int64
+
+	mu sync.Mutex
+	// completed holds the completed inserts above count, waiting for earlier ones.
+	completed map[int64]bool
+}
+
+func newKeyspace(initial int64) *keyspace {
+	return &keyspace{count: initial, next: initial, completed: map[int64]bool{}}
+}
+
+var keyspaces = struct {
+	sync.Mutex
+	byName map[string]*keyspace
+}{byName: map[string]*keyspace{}}
+
+// sharedKeyspace returns the keyspace registered under name, creating it with initial records
+// when it does not exist yet.
+func sharedKeyspace(name string, initial int64) *keyspace {
+	keyspaces.Lock()
+	defer keyspaces.Unlock()
+	ks, ok := keyspaces.byName[name]
+	if !ok {
+		ks = newKeyspace(initial)
+		keyspaces.byName[name] = ks
+	}
+	return ks
+}
+
+func (ks *keyspace) size() int64 {
+	return atomic.LoadInt64(&ks.count)
+}
+
+// allocate reserves the next key for an insert. The key stays invisible until acknowledged.
+func (ks *keyspace) allocate() int64 {
+	return atomic.AddInt64(&ks.next, 1) - 1
+}
+
+// allocated returns the number of keys allocated so far, visible or not.
+func (ks *keyspace) allocated() int64 {
+	return atomic.LoadInt64(&ks.next)
+}
+
+// acknowledge marks the insert of index as completed. Workloads acknowledge failed inserts too, as
+// YCSB does, so that one failed insert does not stop the keyspace from growing.
+func (ks *keyspace) acknowledge(index int64) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	count := atomic.LoadInt64(&ks.count)
+	if index < count {
+		return
+	}
+	if index != count {
+		ks.completed[index] = true
+		return
+	}
+	for count++; ks.completed[count]; count++ {
+		delete(ks.completed, count)
+	}
+	atomic.StoreInt64(&ks.count, count)
+}
+
+// keyDistribution picks the index of an existing record.
+type keyDistribution interface {
+	next(rng *rand.Rand) int64
+}
+
+// newKeyDistribution creates the named distribution over ks. hotsetFraction and hotOpnFraction
+// only apply to the hotspot distribution and default to 0.2 and 0.8 when zero.
+func newKeyDistribution(name string, ks *keyspace, hotsetFraction, hotOpnFraction float64) (keyDistribution, error) {
+	switch name {
+	case "", distributionUniform:
+		return &uniformKeys{ks}, nil
+	case distributionZipfian:
+		return &zipfianKeys{ks, newZipfian(ks.size(), zipfianConstant)}, nil
+	case distributionScrambledZipfian:
+		return &scrambledZipfianKeys{ks, newZipfian(ks.size(), zipfianConstant)}, nil
+	case distributionHotspot:
+		if hotsetFraction <= 0 || hotsetFraction > 1 {
+			hotsetFraction = defaultHotsetFraction
+		}
+		if hotOpnFraction <= 0 || hotOpnFraction > 1 {
+			hotOpnFraction = defaultHotOpnFraction
+		}
+		return &hotspotKeys{ks, hotsetFraction, hotOpnFraction}, nil
+	case distributionLatest:
+		return &latestKeys{ks, newZipfian(ks.size(), zipfianConstant)}, nil
+	case distributionSequential:
+		return &sequentialKeys{ks}, nil
+	default:
+		return nil, fmt.Errorf("unknown key distribution %s", name)
+	}
+}
+
+type uniformKeys struct {
+	ks *keyspace
+}
+
+func (d *uniformKeys) next(rng *rand.Rand) int64 {
+	n := d.ks.size()
+	if n <= 0 {
+		return 0
+	}
+	return rng.Int63n(n)
+}
+
+// zipfianKeys favours the first records of the keyspace.
+type zipfianKeys struct {
+	ks   *keyspace
+	zipf *zipfian
+}
+
+func (d *zipfianKeys) next(rng *rand.Rand) int64 {
+	return d.zipf.next(rng, d.ks.size())
+}
+
+// scrambledZipfianKeys has the popularity skew of zipfianKeys, but spreads the popular records
+// across the keyspace instead of clustering them at its start.
+type scrambledZipfianKeys struct {
+	ks   *keyspace
+	zipf *zipfian
+}
+
+func (d *scrambledZipfianKeys) next(rng *rand.Rand) int64 {
+	n := d.ks.size()
+	if n <= 0 {
+		return 0
+	}
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], uint64(d.zipf.next(rng, n)))
+	h := fnv.New64a()
+	h.Write(b[:])
+	return int64(h.Sum64() % uint64(n))
+}
+
+// hotspotKeys sends hotOpnFraction of the accesses to the first hotsetFraction of the records,
+// uniformly, and the rest to the other records.
+type hotspotKeys struct {
+	ks                             *keyspace
+	hotsetFraction, hotOpnFraction float64
+}
+
+func (d *hotspotKeys) next(rng *rand.Rand) int64 {
+	n := d.ks.size()
+	hot := int64(float64(n) * d.hotsetFraction)
+	if hot <= 0 || hot >= n {
+		return (&uniformKeys{d.ks}).next(rng)
+	}
+	if rng.Float64() < d.hotOpnFraction {
+		return rng.Int63n(hot)
+	}
+	return hot + rng.Int63n(n-hot)
+}
+
+// sequentialKeys walks the keyspace in order, wrapping around at its end. The position is shared
+// by every chooser of the keyspace.
+type sequentialKeys struct {
+	ks *keyspace
+}
+
+func (d *sequentialKeys) next(*rand.Rand) int64 {
+	n := d.ks.size()
+	if n <= 0 {
+		return 0
+	}
+	return (atomic.AddInt64(&d.ks.cursor, 1) - 1) % n
+}
+
+// latestKeys favours the most recently inserted records.
+type latestKeys struct {
+	ks   *keyspace
+	zipf *zipfian
+}
+
+func (d *latestKeys) next(rng *rand.Rand) int64 {
+	n := d.ks.size()
+	if n <= 0 {
+		return 0
+	}
+	return n - 1 - d.zipf.next(rng, n)
+}
+
+// zipfian draws from a Zipfian distribution over [0, items) using the algorithm from Gray et
+// al., "Quickly Generating Billion-Record Synthetic Databases", as YCSB does. The zeta constant
+// is extended incrementally when the item count grows.
+type zipfian struct {
+	theta, alpha, zeta2 float64
+	items               int64
+	zetan, eta          float64
+}
+
+// zetaCache avoids recomputing the O(n) zeta constant for every VU of a test.
+var zetaCache sync.Map
+
+type zetaKey struct {
+	items int64
+	theta float64
+}
+
+func newZipfian(items int64, theta float64) *zipfian {
+	z := &zipfian{
+		theta: theta,
+		alpha: 1 / (1 - theta),
+		zeta2: zeta(0, 2, theta, 0),
+	}
+	if cached, ok := zetaCache.Load(zetaKey{items, theta}); ok {
+		z.items, z.zetan = items, cached.(float64)
+	} else {
+		z.resize(items)
+		zetaCache.Store(zetaKey{items, theta}, z.zetan)
+	}
+	z.updateEta()
+	return z
+}
+
+// zeta adds the terms from+1 to n of the zeta sum to initial.
+func zeta(from, n int64, theta, initial float64) float64 {
+	sum := initial
+	for i := from; i < n; i++ {
+		sum += 1 / math.Pow(float64(i+1), theta)
+	}
+	return sum
+}
+
+func (z *zipfian) resize(items int64) {
+	if items < z.items {
+		z.items, z.zetan = 0, 0
+	}
+	z.zetan = zeta(z.items, items, z.theta, z.zetan)
+	z.items = items
+	z.updateEta()
+}
+
+func (z *zipfian) updateEta() {
+	z.eta = (1 - math.Pow(2/float64(z.items), 1-z.theta)) / (1 - z.zeta2/z.zetan)
+}
+
+func (z *zipfian) next(rng *rand.Rand, items int64) int64 {
+	if items <= 1 {
+		return 0
+	}
+	if items != z.items {
+		z.resize(items)
+	}
+	u := rng.Float64()
+	uz := u * z.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, z.theta) {
+		return 1
+	}
+	n := int64(float64(items) * math.Pow(z.eta*u-z.eta+1, z.alpha))
+	if n >= items {
+		n = items - 1
+	}
+	return n
+}
@@ -0,0 +1,41 @@
+package mongo
+
+import (
+	"reflect"
+	"strconv"
+
+	"github.com/dop251/goja"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var typeJSObject = reflect.TypeOf(map[string]interface{}{})
+
+// toDocument converts a JS value into a BSON-friendly Go value while keeping the key order of
+// objects. Plain exports turn objects into maps, which loses ordering that commands and sort
+// specifications depend on.
+func toDocument(v goja.Value) interface{} {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil
+	}
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return v.Export()
+	}
+	switch {
+	case obj.ClassName() == "Array":
+		length := int(obj.Get("length").ToInteger())
+		arr := make(bson.A, 0, length)
+		for i := 0; i < length; i++ {
+			arr = append(arr, toDocument(obj.Get(strconv.Itoa(i))))
+		}
+		return arr
+	case obj.ExportType() == typeJSObject:
+		doc := bson.D{}
+		for _, key := range obj.Keys() {
+			doc = append(doc, bson.E{Key: key, Value: toDocument(obj.Get(key))})
+		}
+		return doc
+	default:
+		return obj.Export()
+	}
+}
@@ -0,0 +1,88 @@
+package mongo
+
+import (
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/auth"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/topology"
+)
+
+const (
+	errorClassNetwork         = "network"
+	errorClassTimeout         = "timeout"
+	errorClassServerSelection = "server_selection"
+	errorClassDuplicateKey    = "duplicate_key"
+	errorClassWriteConflict   = "write_conflict"
+	errorClassNotPrimary      = "not_primary"
+	errorClassAuth            = "auth"
+	errorClassValidation      = "validation"
+	errorClassCursorNotFound  = "cursor_not_found"
+	errorClassOther           = "other"
+)
+
+var (
+	notPrimaryCodes = []int{
+		errorCodes["NotWritablePrimary"],
+		errorCodes["NotPrimaryNoSecondaryOk"],
+		errorCodes["NotPrimaryOrSecondary"],
+		errorCodes["PrimarySteppedDown"],
+		errorCodes["InterruptedDueToReplStateChange"],
+	}
+	authCodes = []int{
+		13, // Unauthorized
+		18, // AuthenticationFailed
+	}
+)
+
+const (
+	documentValidationFailureCode = 121
+	cursorNotFoundCode            = 43
+)
+
+// classifyError sorts an operation error into one of a fixed set of classes, so that it can be
+// used as a tag value without growing the number of time series.
+func classifyError(err error) string {
+	var authErr *auth.Error
+	if errors.As(err, &authErr) {
+		return errorClassAuth
+	}
+	if errors.As(err, &topology.ServerSelectionError{}) {
+		return errorClassServerSelection
+	}
+	if mongo.IsTimeout(err) {
+		return errorClassTimeout
+	}
+	if mongo.IsNetworkError(err) {
+		return errorClassNetwork
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return errorClassDuplicateKey
+	}
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return errorClassOther
+	}
+	switch {
+	case serverErr.HasErrorCode(errorCodes["WriteConflict"]), serverErr.HasErrorLabel("TransientTransactionError"):
+		return errorClassWriteConflict
+	case hasAnyErrorCode(serverErr, notPrimaryCodes):
+		return errorClassNotPrimary
+	case hasAnyErrorCode(serverErr, authCodes):
+		return errorClassAuth
+	case serverErr.HasErrorCode(documentValidationFailureCode):
+		return errorClassValidation
+	case serverErr.HasErrorCode(cursorNotFoundCode):
+		return errorClassCursorNotFound
+	}
+	return errorClassOther
+}
+
+func hasAnyErrorCode(err mongo.ServerError, codes []int) bool {
+	for _, code := range codes {
+		if err.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,126 @@
+package mongo
+
+import (
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// logicalOperators take arrays of sub-queries whose shapes matter, unlike the value lists of
+// operators such as $in.
+var logicalOperators = map[string]bool{"$and": true, "$or": true, "$nor": true}
+
+// fingerprint normalizes a filter, update or pipeline into its query shape: keys are sorted and
+// every value is replaced by "?", so queries that differ only in their values share a
+// fingerprint. Arrays of values collapse to "[?]" whatever their length.
+func fingerprint(value interface{}) string {
+	var b strings.Builder
+	writeShape(&b, normalizeShapeValue(value), false)
+	return b.String()
+}
+
+// normalizeShapeValue turns the document representations used across the package into
+// bson.M and bson.A so that writeShape only has to handle those.
+func normalizeShapeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case bson.D:
+		m := make(bson.M, len(v))
+		for _, e := range v {
+			m[e.Key] = normalizeShapeValue(e.Value)
+		}
+		return m
+	case bson.M:
+		m := make(bson.M, len(v))
+		for k, e := range v {
+			m[k] = normalizeShapeValue(e)
+		}
+		return m
+	case map[string]interface{}:
+		return normalizeShapeValue(bson.M(v))
+	case bson.A:
+		a := make(bson.A, 0, len(v))
+		for _, e := range v {
+			a = append(a, normalizeShapeValue(e))
+		}
+		return a
+	case []interface{}:
+		return normalizeShapeValue(bson.A(v))
+	case bson.Raw:
+		var m bson.M
+		if bson.Unmarshal(v, &m) != nil {
+			return nil
+		}
+		return normalizeShapeValue(m)
+	case bson.RawValue:
+		switch v.Type {
+		case bsontype.EmbeddedDocument:
+			return normalizeShapeValue(v.Document())
+		case bsontype.Array:
+			var a bson.A
+			if v.Unmarshal(&a) != nil {
+				return nil
+			}
+			return normalizeShapeValue(a)
+		}
+		return nil
+	default:
+		return value
+	}
+}
+
+// writeShape writes value's shape. Arrays are only expanded when structural is set, which is
+// the case for pipelines and the operands of logical operators.
+func writeShape(b *strings.Builder, value interface{}, structural bool) {
+	switch v := value.(type) {
+	case bson.M:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(k)
+			b.WriteByte(':')
+			writeShape(b, v[k], logicalOperators[k])
+		}
+		b.WriteByte('}')
+	case bson.A:
+		if !structural {
+			b.WriteString("[?]")
+			return
+		}
+		b.WriteByte('[')
+		for i, e := range v {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeShape(b, e, false)
+		}
+		b.WriteByte(']')
+	default:
+		b.WriteByte('?')
+	}
+}
+
+// Fingerprint is the exported form of fingerprint for scripts. Arrays given at the top level
+// are treated as aggregation pipelines.
+func Fingerprint(query interface{}) string {
+	if _, ok := query.([]interface{}); ok {
+		return pipelineFingerprint(query)
+	}
+	return fingerprint(query)
+}
+
+// pipelineFingerprint is the fingerprint of an aggregation pipeline, where the stages
+// themselves are part of the shape.
+func pipelineFingerprint(pipeline interface{}) string {
+	var b strings.Builder
+	writeShape(&b, normalizeShapeValue(pipeline), true)
+	return b.String()
+}
@@ -0,0 +1,329 @@
+package mongo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// paddingField is added to generated documents that are smaller than the requested target size.
+const paddingField = "_pad"
+
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// seededEpoch stands in for the current time in seeded generation, so that ObjectId timestamps
+// and default date ranges do not depend on when the script runs.
+var seededEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	firstNames   = []string{"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth", "William", "Barbara", "Richard", "Susan", "Joseph", "Jessica", "Thomas", "Sarah", "Charles", "Karen", "Somchai", "Malee", "Hiroshi", "Yuki", "Wei", "Mei", "Carlos", "Sofia", "Ahmed", "Fatima"}
+	lastNames    = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez", "Hernandez", "Lopez", "Wilson", "Anderson", "Taylor", "Thomas", "Moore", "Jackson", "Martin", "Lee", "Saetang", "Wongsakul", "Tanaka", "Suzuki", "Wang", "Chen", "Silva", "Santos", "Khan", "Ali"}
+	emailDomains = []string{"example.com", "example.org", "example.net", "mail.test", "corp.test"}
+	words        = []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet", "kilo", "lima", "mike", "november", "oscar", "papa", "quebec", "romeo", "sierra", "tango", "uniform", "victor", "whiskey", "xray", "yankee", "zulu"}
+)
+
+type GenerateOptions struct {
+	// Seed makes the generated values reproducible. Zero picks a random seed. With a seed,
+	// ObjectId timestamps and the default $date range are based on 2024-01-01 instead of the
+	// current time.
+	Seed int64 `js:"seed"`
+	// TargetSize pads each document with a random string until its BSON size reaches this many
+	// bytes.
+	TargetSize int `js:"targetSize"`
+}
+
+// valueGenerator produces the value for one template node of the i-th generated document.
+type valueGenerator func(rng *rand.Rand, i int) interface{}
+
+// documentGenerator produces documents from a compiled template one at a time, so large data
+// sets can be streamed instead of held in memory.
+type documentGenerator struct {
+	gen        valueGenerator
+	rng        *rand.Rand
+	targetSize int
+	index      int
+}
+
+func newDocumentGenerator(template map[string]interface{}, opts GenerateOptions) (*documentGenerator, error) {
+	now, seed := seededEpoch, opts.Seed
+	if seed == 0 {
+		now = time.Now()
+		seed = now.UnixNano()
+	}
+	gen, err := compileTemplate(template, now)
+	if err != nil {
+		return nil, err
+	}
+	return &documentGenerator{
+		gen:        gen,
+		rng:        rand.New(rand.NewSource(seed)),
+		targetSize: opts.TargetSize,
+	}, nil
+}
+
+func (g *documentGenerator) next() (bson.D, error) {
+	doc, ok := g.gen(g.rng, g.index).(bson.D)
+	if !ok {
+		return nil, errors.New("template must be a document, not a generator")
+	}
+	g.index++
+	if g.targetSize > 0 {
+		var err error
+		if doc, err = padDocument(g.rng, doc, g.targetSize); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// Generate fills template count times. Template values are copied as-is, except for nested
+// objects and arrays, which are generated recursively, and single-key objects naming a
+// generator, such as {$int: {min: 0, max: 10}} or {$enum: ["a", "b"]}.
+func Generate(template map[string]interface{}, count int, opts GenerateOptions) ([]interface{}, error) {
+	g, err := newDocumentGenerator(template, opts)
+	if err != nil {
+		return nil, err
+	}
+	docs := make([]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		doc, err := g.next()
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// compileTemplate builds the generator of template. now is the time ObjectIds are stamped with and
+// default date ranges end at.
+func compileTemplate(template interface{}, now time.Time) (valueGenerator, error) {
+	switch t := template.(type) {
+	case map[string]interface{}:
+		if len(t) == 1 {
+			for key, spec := range t {
+				if strings.HasPrefix(key, "$") {
+					return compileGenerator(key, spec, now)
+				}
+			}
+		}
+		// Fields are generated, and laid out, in key order so that a seed always yields the same
+		// documents, byte for byte.
+		keys := make([]string, 0, len(t))
+		for key := range t {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		fields := make([]valueGenerator, 0, len(keys))
+		for _, key := range keys {
+			gen, err := compileTemplate(t[key], now)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			fields = append(fields, gen)
+		}
+		return func(rng *rand.Rand, i int) interface{} {
+			doc := make(bson.D, 0, len(fields))
+			for j, gen := range fields {
+				doc = append(doc, bson.E{Key: keys[j], Value: gen(rng, i)})
+			}
+			return doc
+		}, nil
+	case []interface{}:
+		elems := make([]valueGenerator, 0, len(t))
+		for _, value := range t {
+			gen, err := compileTemplate(value, now)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, gen)
+		}
+		return func(rng *rand.Rand, i int) interface{} {
+			arr := make(bson.A, 0, len(elems))
+			for _, gen := range elems {
+				arr = append(arr, gen(rng, i))
+			}
+			return arr
+		}, nil
+	default:
+		return func(*rand.Rand, int) interface{} { return template }, nil
+	}
+}
+
+func compileGenerator(name string, spec interface{}, now time.Time) (valueGenerator, error) {
+	params, _ := spec.(map[string]interface{})
+	switch name {
+	case "$objectId":
+		return func(rng *rand.Rand, _ int) interface{} {
+			var id primitive.ObjectID
+			binary.BigEndian.PutUint32(id[0:4], uint32(now.Unix()))
+			binary.BigEndian.PutUint64(id[4:12], rng.Uint64())
+			return id
+		}, nil
+	case "$int":
+		lo, hi := int64(floatParam(params, "min", 0)), int64(floatParam(params, "max", 1000))
+		if hi < lo {
+			return nil, fmt.Errorf("%s: max is lower than min", name)
+		}
+		return func(rng *rand.Rand, _ int) interface{} {
+			return lo + rng.Int63n(hi-lo+1)
+		}, nil
+	case "$double":
+		lo, hi := floatParam(params, "min", 0), floatParam(params, "max", 1)
+		return func(rng *rand.Rand, _ int) interface{} {
+			return lo + rng.Float64()*(hi-lo)
+		}, nil
+	case "$bool":
+		return func(rng *rand.Rand, _ int) interface{} {
+			return rng.Intn(2) == 1
+		}, nil
+	case "$sequence":
+		start := int64(floatParam(params, "start", 0))
+		return func(_ *rand.Rand, i int) interface{} {
+			return start + int64(i)
+		}, nil
+	case "$date":
+		from, err := timeParam(params, "from", now.AddDate(-1, 0, 0))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		to, err := timeParam(params, "to", now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		span := to.Sub(from)
+		if span <= 0 {
+			return nil, fmt.Errorf("%s: to must be after from", name)
+		}
+		return func(rng *rand.Rand, _ int) interface{} {
+			return from.Add(time.Duration(rng.Int63n(int64(span))))
+		}, nil
+	case "$string":
+		length := int(floatParam(params, "length", 16))
+		if length < 0 {
+			return nil, fmt.Errorf("%s: length must not be negative", name)
+		}
+		return func(rng *rand.Rand, _ int) interface{} {
+			return randomString(rng, length)
+		}, nil
+	case "$word":
+		return pick(words), nil
+	case "$firstName":
+		return pick(firstNames), nil
+	case "$lastName":
+		return pick(lastNames), nil
+	case "$name":
+		return func(rng *rand.Rand, _ int) interface{} {
+			return firstNames[rng.Intn(len(firstNames))] + " " + lastNames[rng.Intn(len(lastNames))]
+		}, nil
+	case "$email":
+		return func(rng *rand.Rand, _ int) interface{} {
+			return fmt.Sprintf("%s.%s%d@%s",
+				strings.ToLower(firstNames[rng.Intn(len(firstNames))]),
+				strings.ToLower(lastNames[rng.Intn(len(lastNames))]),
+				rng.Intn(10000),
+				emailDomains[rng.Intn(len(emailDomains))])
+		}, nil
+	case "$enum":
+		values, ok := spec.([]interface{})
+		if !ok || len(values) == 0 {
+			return nil, fmt.Errorf("%s expects a non-empty array of values", name)
+		}
+		return func(rng *rand.Rand, _ int) interface{} {
+			return values[rng.Intn(len(values))]
+		}, nil
+	case "$array":
+		of, err := compileTemplate(params["of"], now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		size := -1
+		if params["size"] != nil {
+			if size = int(floatParam(params, "size", 0)); size < 0 {
+				return nil, fmt.Errorf("%s: size must not be negative", name)
+			}
+		}
+		lo, hi := int(floatParam(params, "min", 0)), int(floatParam(params, "max", 10))
+		if size < 0 && lo < 0 {
+			return nil, fmt.Errorf("%s: min must not be negative", name)
+		}
+		if size < 0 && hi < lo {
+			return nil, fmt.Errorf("%s: max is lower than min", name)
+		}
+		return func(rng *rand.Rand, i int) interface{} {
+			n := size
+			if n < 0 {
+				n = lo + rng.Intn(hi-lo+1)
+			}
+			arr := make(bson.A, 0, n)
+			for j := 0; j < n; j++ {
+				arr = append(arr, of(rng, i))
+			}
+			return arr
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown generator %s", name)
+	}
+}
+
+// padDocument appends a string field sized so that the marshalled document is exactly target
+// bytes, or leaves the document alone when it is already at least that large.
+func padDocument(rng *rand.Rand, doc bson.D, target int) (bson.D, error) {
+	raw, err := bson.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	// A string element costs a type byte, the NUL-terminated key, an int32 length and a NUL.
+	overhead := 1 + len(paddingField) + 1 + 4 + 1
+	if missing := target - len(raw) - overhead; missing > 0 {
+		doc = append(doc, bson.E{Key: paddingField, Value: randomString(rng, missing)})
+	}
+	return doc, nil
+}
+
+func pick(values []string) valueGenerator {
+	return func(rng *rand.Rand, _ int) interface{} {
+		return values[rng.Intn(len(values))]
+	}
+}
+
+func randomString(rng *rand.Rand, length int) string {
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = alphanumeric[rng.Intn(len(alphanumeric))]
+	}
+	return string(b)
+}
+
+func floatParam(params map[string]interface{}, key string, def float64) float64 {
+	switch v := params[key].(type) {
+	case int64:
+		return float64(v)
+	case float64:
+		return v
+	default:
+		return def
+	}
+}
+
+// timeParam accepts JS Dates as well as RFC 3339 timestamps and plain dates.
+func timeParam(params map[string]interface{}, key string, def time.Time) (time.Time, error) {
+	switch v := params[key].(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		return time.Parse(time.DateOnly, v)
+	default:
+		return def, nil
+	}
+}
@@ -0,0 +1,153 @@
+package mongo
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/dop251/goja"
+	k6modules "go.k6.io/k6/js/modules"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type gridFSBucket struct {
+	Bucket   *gridfs.Bucket
+	Name     string
+	vu       k6modules.VU
+	Reporter *Reporter
+}
+
+type gridFSDownloadStream struct {
+	Stream *gridfs.DownloadStream
+	bucket *gridFSBucket
+}
+
+// Gridfs opens the named GridFS bucket, or the default "fs" bucket when bucketName is empty.
+func (d *database) Gridfs(bucketName string) (*gridFSBucket, error) {
+	if bucketName == "" {
+		bucketName = options.DefaultName
+	}
+	bucket, err := gridfs.NewBucket(d.Database, options.GridFSBucket().SetName(bucketName))
+	if err != nil {
+		return nil, err
+	}
+	return &gridFSBucket{
+		bucket,
+		bucketName,
+		d.vu,
+		d.Reporter,
+	}, nil
+}
+
+// Upload stores data, an ArrayBuffer from open(path, 'b') or a string, as a new file and returns
+// its id.
+func (b *gridFSBucket) Upload(name string, data []byte, metadata interface{}) (primitive.ObjectID, error) {
+	b.Reporter.bindDialer()
+	defer b.Reporter.flushConnections()
+	opts := options.GridFSUpload()
+	if metadata != nil {
+		opts.SetMetadata(metadata)
+	}
+	id, err := b.Bucket.UploadFromStream(name, bytes.NewReader(data), opts)
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	b.Reporter.gridFSBytes(b.Name, "upload", len(data), 0)
+	return id, nil
+}
+
+// Download reads the whole file into an ArrayBuffer.
+func (b *gridFSBucket) Download(id interface{}) (goja.ArrayBuffer, error) {
+	b.Reporter.bindDialer()
+	defer b.Reporter.flushConnections()
+	var buf bytes.Buffer
+	n, err := b.Bucket.DownloadToStream(fileID(id), &buf)
+	if err != nil {
+		return goja.ArrayBuffer{}, err
+	}
+	b.Reporter.gridFSBytes(b.Name, "download", 0, int(n))
+	return b.vu.Runtime().NewArrayBuffer(buf.Bytes()), nil
+}
+
+func (b *gridFSBucket) OpenDownloadStream(id interface{}) (*gridFSDownloadStream, error) {
+	b.Reporter.bindDialer()
+	defer b.Reporter.flushConnections()
+	stream, err := b.Bucket.OpenDownloadStream(fileID(id))
+	if err != nil {
+		return nil, err
+	}
+	return &gridFSDownloadStream{stream, b}, nil
+}
+
+// Find returns the files collection documents matching filter.
+func (b *gridFSBucket) Find(filter interface{}) ([]bson.M, error) {
+	ctx := context.TODO()
+	b.Reporter.bindDialer()
+	defer b.Reporter.flushConnections()
+	if filter == nil {
+		filter = bson.D{}
+	}
+	cursor, err := b.Bucket.FindContext(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var files []bson.M
+	if err = cursor.All(ctx, &files); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (b *gridFSBucket) Delete(id interface{}) error {
+	ctx := context.TODO()
+	b.Reporter.bindDialer()
+	defer b.Reporter.flushConnections()
+	return b.Bucket.DeleteContext(ctx, fileID(id))
+}
+
+func (b *gridFSBucket) Rename(id interface{}, newName string) error {
+	ctx := context.TODO()
+	b.Reporter.bindDialer()
+	defer b.Reporter.flushConnections()
+	return b.Bucket.RenameContext(ctx, fileID(id), newName)
+}
+
+// Read returns up to size bytes of the file, or null once the whole file has been read.
+func (s *gridFSDownloadStream) Read(size int) (goja.Value, error) {
+	defer s.bucket.Reporter.flushConnections()
+	if size <= 0 {
+		size = int(options.DefaultChunkSize)
+	}
+	p := make([]byte, size)
+	n, err := s.Stream.Read(p)
+	if n > 0 {
+		s.bucket.Reporter.gridFSBytes(s.bucket.Name, "openDownloadStream", 0, n)
+	}
+	if errors.Is(err, io.EOF) {
+		if n == 0 {
+			return goja.Null(), nil
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return s.bucket.vu.Runtime().ToValue(s.bucket.vu.Runtime().NewArrayBuffer(p[:n])), nil
+}
+
+func (s *gridFSDownloadStream) Close() error {
+	return s.Stream.Close()
+}
+
+// fileID lets scripts refer to files by the hex string of their ObjectID as well as by the id
+// returned from Upload.
+func fileID(id interface{}) interface{} {
+	if hex, ok := id.(string); ok {
+		if oid, err := primitive.ObjectIDFromHex(hex); err == nil {
+			return oid
+		}
+	}
+	return id
+}
@@ -0,0 +1,138 @@
+package mongo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+
+	"go.k6.io/k6/metrics"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// handshakeCommands are the commands a connection sends while it is being established.
+var handshakeCommands = map[string]bool{
+	"hello":        true,
+	"isMaster":     true,
+	"ismaster":     true,
+	"saslStart":    true,
+	"saslContinue": true,
+	"authenticate": true,
+}
+
+// handshakeConn times the MongoDB handshake of a connection, the hello command and the
+// authentication conversation that follows it, by watching the commands the driver writes. The
+// handshake ends with the last reply read before the first other command. Only handshakes that
+// authenticate are reported, which leaves out the driver's monitoring connections.
+type handshakeConn struct {
+	net.Conn
+	dialer *dialer
+	host   string
+
+	mu            sync.Mutex
+	done          bool
+	started       time.Time
+	lastRead      time.Time
+	authenticated bool
+}
+
+func (c *handshakeConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	if !c.done {
+		c.observeWrite(b)
+	}
+	c.mu.Unlock()
+	return c.Conn.Write(b)
+}
+
+func (c *handshakeConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.mu.Lock()
+	if !c.done && !c.started.IsZero() && n > 0 {
+		c.lastRead = time.Now()
+	}
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *handshakeConn) Close() error {
+	c.mu.Lock()
+	if !c.done {
+		c.finish()
+	}
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+func (c *handshakeConn) observeWrite(b []byte) {
+	name, doc, ok := wireCommand(b)
+	if !ok || !handshakeCommands[name] || (!c.started.IsZero() && (name == "hello" || name == "isMaster" || name == "ismaster")) {
+		c.finish()
+		return
+	}
+	if c.started.IsZero() {
+		c.started = time.Now()
+		if _, err := doc.LookupErr("speculativeAuthenticate"); err == nil {
+			c.authenticated = true
+		}
+		return
+	}
+	c.authenticated = true
+}
+
+func (c *handshakeConn) finish() {
+	c.done = true
+	if c.authenticated && c.lastRead.After(c.started) {
+		c.dialer.record(func(m *Metrics) *metrics.Metric { return m.AuthDuration }, c.lastRead.Sub(c.started), map[string]string{"host": c.host})
+	}
+}
+
+// wireCommand returns the name and body of the command in an OP_MSG or OP_QUERY wire message.
+func wireCommand(b []byte) (string, bson.Raw, bool) {
+	if len(b) < 16 {
+		return "", nil, false
+	}
+	body := b[16:]
+	switch binary.LittleEndian.Uint32(b[12:16]) {
+	case opMsg:
+		// Flag bits, then a section of kind 0 holding the command document.
+		if len(body) < 5 || body[4] != 0 {
+			return "", nil, false
+		}
+		body = body[5:]
+	case opQuery:
+		// Flags, the NUL-terminated collection name, numberToSkip and numberToReturn.
+		if len(body) < 4 {
+			return "", nil, false
+		}
+		end := bytes.IndexByte(body[4:], 0)
+		if end < 0 || len(body) < 4+end+1+8 {
+			return "", nil, false
+		}
+		body = body[4+end+1+8:]
+	default:
+		return "", nil, false
+	}
+	if len(body) < 4 {
+		return "", nil, false
+	}
+	length := int(binary.LittleEndian.Uint32(body))
+	if length < 5 || length > len(body) {
+		return "", nil, false
+	}
+	doc := bson.Raw(body[:length])
+	if err := doc.Validate(); err != nil {
+		return "", nil, false
+	}
+	elems, err := doc.Elements()
+	if err != nil || len(elems) == 0 {
+		return "", nil, false
+	}
+	return elems[0].Key(), doc, true
+}
@@ -0,0 +1,304 @@
+package mongo
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+	formatBSON   = "bson"
+)
+
+type ExportOptions struct {
+	// Format is one of json (a JSON array, like mongoexport --jsonArray), ndjson (mongoexport's
+	// default output), csv or bson (a mongodump collection file). It defaults to the format
+	// matching the file extension.
+	Format     string      `js:"format"`
+	Filter     interface{} `js:"filter"`
+	Projection interface{} `js:"projection"`
+	// Fields lists the columns of a CSV export. Nested fields use dot notation.
+	Fields []string `js:"fields"`
+	// Canonical writes canonical instead of relaxed Extended JSON.
+	Canonical bool `js:"canonical"`
+}
+
+type ImportOptions struct {
+	Format string `js:"format"`
+	// Drop drops the collection before importing, like mongoimport --drop.
+	Drop      bool `js:"drop"`
+	BatchSize int  `js:"batchSize"`
+	Workers   int  `js:"workers"`
+}
+
+// ExportTo writes the documents matching opts.Filter to path and returns how many were written.
+// Paths are relative to the working directory of the k6 process.
+func (m *mongodb) ExportTo(path string, opts ExportOptions) (int64, error) {
+	ctx := context.TODO()
+	m.Reporter.bindDialer()
+	defer m.Reporter.flushConnections()
+	format, err := fileFormat(path, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+	if format == formatCSV && len(opts.Fields) == 0 {
+		return 0, errors.New("csv exports require fields")
+	}
+	filter := opts.Filter
+	if filter == nil {
+		filter = bson.D{}
+	}
+	findOpts := options.Find()
+	if opts.Projection != nil {
+		findOpts.SetProjection(opts.Projection)
+	}
+	cursor, err := m.Collection.Find(ctx, filter, findOpts)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	var csvWriter *csv.Writer
+	switch format {
+	case formatJSON:
+		_, err = w.WriteString("[")
+	case formatCSV:
+		csvWriter = csv.NewWriter(w)
+		err = csvWriter.Write(opts.Fields)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for cursor.Next(ctx) {
+		switch format {
+		case formatBSON:
+			_, err = w.Write(cursor.Current)
+		case formatCSV:
+			err = csvWriter.Write(csvRecord(cursor.Current, opts.Fields))
+		default:
+			var line []byte
+			line, err = bson.MarshalExtJSON(cursor.Current, opts.Canonical, false)
+			if err == nil && format == formatJSON && count > 0 {
+				_, err = w.WriteString(",")
+			}
+			if err == nil {
+				_, err = w.Write(line)
+			}
+			if err == nil && format == formatNDJSON {
+				err = w.WriteByte('\n')
+			}
+		}
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err = cursor.Err(); err != nil {
+		return count, err
+	}
+	switch format {
+	case formatJSON:
+		_, err = w.WriteString("]\n")
+	case formatCSV:
+		csvWriter.Flush()
+		err = csvWriter.Error()
+	}
+	if err != nil {
+		return count, err
+	}
+	return count, w.Flush()
+}
+
+// ImportFrom loads a file written by ExportTo, mongoexport or mongodump into the collection and
+// returns how many documents were inserted. CSV files must start with a header line.
+func (m *mongodb) ImportFrom(path string, opts ImportOptions) (int64, error) {
+	ctx := context.TODO()
+	m.Reporter.bindDialer()
+	defer m.Reporter.flushConnections()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	format, err := fileFormat(path, opts.Format)
+	if err != nil {
+		return 0, err
+	}
+	var src documentSource
+	switch format {
+	case formatBSON:
+		src = &bsonFileSource{data}
+	case formatCSV:
+		src, err = newCSVSource(data)
+	default:
+		src = newFileSource(data)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if opts.Drop {
+		if err = m.Collection.Drop(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return m.insertFrom(src, SeedOptions{BatchSize: opts.BatchSize, Workers: opts.Workers})
+}
+
+// fileFormat returns the explicit format, which must be one of the supported ones, or the format
+// matching the file extension, JSON for unknown extensions.
+func fileFormat(path, format string) (string, error) {
+	if format != "" {
+		switch format = strings.ToLower(format); format {
+		case formatJSON, formatNDJSON, formatCSV, formatBSON:
+			return format, nil
+		default:
+			return "", fmt.Errorf("unknown file format %s, expected json, ndjson, csv or bson", format)
+		}
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return formatNDJSON, nil
+	case ".csv":
+		return formatCSV, nil
+	case ".bson":
+		return formatBSON, nil
+	default:
+		return formatJSON, nil
+	}
+}
+
+// bsonFileSource reads the concatenated documents of a mongodump collection file.
+type bsonFileSource struct {
+	data []byte
+}
+
+func (s *bsonFileSource) next() (interface{}, error) {
+	if len(s.data) == 0 {
+		return nil, io.EOF
+	}
+	if len(s.data) < 4 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	size := int(binary.LittleEndian.Uint32(s.data))
+	if size < 5 || size > len(s.data) {
+		return nil, fmt.Errorf("invalid BSON document length %d", size)
+	}
+	doc := bson.Raw(s.data[:size])
+	s.data = s.data[size:]
+	return doc, doc.Validate()
+}
+
+// csvSource converts CSV rows into documents the way mongoimport --headerline does: numbers
+// become numbers and dotted header names become nested documents.
+type csvSource struct {
+	reader *csv.Reader
+	fields [][]string
+}
+
+func newCSVSource(data []byte) (*csvSource, error) {
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	fields := make([][]string, 0, len(header))
+	for _, name := range header {
+		fields = append(fields, strings.Split(name, "."))
+	}
+	return &csvSource{reader, fields}, nil
+}
+
+func (s *csvSource) next() (interface{}, error) {
+	record, err := s.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	doc := bson.M{}
+	for i, path := range s.fields {
+		if i >= len(record) || record[i] == "" {
+			continue
+		}
+		parent := doc
+		for _, key := range path[:len(path)-1] {
+			child, ok := parent[key].(bson.M)
+			if !ok {
+				child = bson.M{}
+				parent[key] = child
+			}
+			parent = child
+		}
+		parent[path[len(path)-1]] = csvValue(record[i])
+	}
+	return doc, nil
+}
+
+func csvValue(s string) interface{} {
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f
+	}
+	return s
+}
+
+func csvRecord(doc bson.Raw, fields []string) []string {
+	record := make([]string, 0, len(fields))
+	for _, field := range fields {
+		value, err := doc.LookupErr(strings.Split(field, ".")...)
+		if err != nil {
+			record = append(record, "")
+			continue
+		}
+		record = append(record, csvString(value))
+	}
+	return record
+}
+
+// csvString formats a value the way mongoexport does for CSV output.
+func csvString(value bson.RawValue) string {
+	switch value.Type {
+	case bsontype.String:
+		return value.StringValue()
+	case bsontype.Int32:
+		return strconv.FormatInt(int64(value.Int32()), 10)
+	case bsontype.Int64:
+		return strconv.FormatInt(value.Int64(), 10)
+	case bsontype.Double:
+		return strconv.FormatFloat(value.Double(), 'f', -1, 64)
+	case bsontype.Boolean:
+		return strconv.FormatBool(value.Boolean())
+	case bsontype.ObjectID:
+		return "ObjectId(" + value.ObjectID().Hex() + ")"
+	case bsontype.DateTime:
+		return primitive.DateTime(value.DateTime()).Time().UTC().Format(time.RFC3339Nano)
+	case bsontype.Null:
+		return ""
+	default:
+		return value.String()
+	}
+}
@@ -0,0 +1,158 @@
+package mongo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	keyTypeInt      = "int"
+	keyTypeString   = "string"
+	keyTypeObjectID = "objectId"
+)
+
+type KeyChooserOptions struct {
+	// Distribution is "uniform", "zipfian", "scrambledZipfian", "hotspot", "latest" or
+	// "sequential".
+	Distribution string `js:"distribution"`
+	// RecordCount is the number of existing records the keys are chosen from.
+	RecordCount int64 `js:"recordCount"`
+	// Keyspace shares the record count, and so the keys allocated by insert, between every chooser
+	// created with the same name in any VU. A workload's keyspace is named after its
+	// "database.collection" namespace.
+	Keyspace string `js:"keyspace"`
+	// Type is "int", "string" or "objectId".
+	Type string `js:"type"`
+	// Prefix is prepended to string keys, which default to "user" followed by the zero-padded
+	// index as in workloads.
+	Prefix         string  `js:"prefix"`
+	HotsetFraction float64 `js:"hotsetFraction"`
+	HotOpnFraction float64 `js:"hotOpnFraction"`
+	Seed           int64   `js:"seed"`
+}
+
+// keyChooser turns record indexes picked by a key distribution into document keys.
+type keyChooser struct {
+	keys         *keyspace
+	distribution keyDistribution
+	rng          *rand.Rand
+	keyType      string
+	prefix       string
+}
+
+// NewKeyChooser creates a key chooser. Without a keyspace name the chooser has a private
+// keyspace of recordCount records.
+func NewKeyChooser(opts KeyChooserOptions) (*keyChooser, error) {
+	switch opts.Type {
+	case "":
+		opts.Type = keyTypeInt
+	case keyTypeInt, keyTypeString, keyTypeObjectID:
+	default:
+		return nil, fmt.Errorf("unknown key type %s", opts.Type)
+	}
+	if opts.Type == keyTypeString && opts.Prefix == "" {
+		opts.Prefix = "user"
+	}
+	var ks *keyspace
+	if opts.Keyspace != "" {
+		ks = sharedKeyspace(opts.Keyspace, opts.RecordCount)
+	} else {
+		ks = newKeyspace(opts.RecordCount)
+	}
+	distribution, err := newKeyDistribution(opts.Distribution, ks, opts.HotsetFraction, opts.HotOpnFraction)
+	if err != nil {
+		return nil, err
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &keyChooser{
+		ks,
+		distribution,
+		rand.New(rand.NewSource(seed)),
+		opts.Type,
+		opts.Prefix,
+	}, nil
+}
+
+// Next returns the key of an existing record.
+func (c *keyChooser) Next() interface{} {
+	return c.key(c.distribution.next(c.rng))
+}
+
+// Insert allocates the key for a new record. Once the script's insert of that record succeeds,
+// Acknowledge adds it to the keyspace, so that it can be chosen by Next, and favoured by the
+// latest distribution, in every VU sharing the keyspace.
+func (c *keyChooser) Insert() interface{} {
+	return c.key(c.keys.allocate())
+}
+
+// Acknowledge makes a key returned by Insert visible. As with YCSB's acknowledged counter, a key
+// only becomes visible once every key allocated before it has been acknowledged too.
+func (c *keyChooser) Acknowledge(key interface{}) error {
+	index, err := c.index(key)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= c.keys.allocated() {
+		return fmt.Errorf("key %v was not allocated by insert", key)
+	}
+	c.keys.acknowledge(index)
+	return nil
+}
+
+// Count returns the number of records in the keyspace.
+func (c *keyChooser) Count() int64 {
+	return c.keys.size()
+}
+
+func (c *keyChooser) key(index int64) interface{} {
+	switch c.keyType {
+	case keyTypeString:
+		return fmt.Sprintf("%s%010d", c.prefix, index)
+	case keyTypeObjectID:
+		return indexObjectID(index)
+	default:
+		return index
+	}
+}
+
+// index is the inverse of key.
+func (c *keyChooser) index(key interface{}) (int64, error) {
+	switch k := key.(type) {
+	case int64:
+		if c.keyType == keyTypeInt {
+			return k, nil
+		}
+	case float64:
+		if c.keyType == keyTypeInt && k == float64(int64(k)) {
+			return int64(k), nil
+		}
+	case string:
+		if c.keyType == keyTypeString && strings.HasPrefix(k, c.prefix) {
+			if index, err := strconv.ParseInt(strings.TrimPrefix(k, c.prefix), 10, 64); err == nil {
+				return index, nil
+			}
+		}
+	case primitive.ObjectID:
+		if c.keyType == keyTypeObjectID {
+			return int64(binary.BigEndian.Uint64(k[4:])), nil
+		}
+	}
+	return 0, fmt.Errorf("%v is not a key of this %s chooser", key, c.keyType)
+}
+
+// indexObjectID maps a record index to a fixed ObjectId, with a zero timestamp and the index in
+// the remaining eight bytes, so the same index always yields the same key.
+func indexObjectID(index int64) primitive.ObjectID {
+	var id primitive.ObjectID
+	binary.BigEndian.PutUint64(id[4:], uint64(index))
+	return id
+}
@@ -0,0 +1,385 @@
+package mongo
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	k6modules "go.k6.io/k6/js/modules"
+	"go.k6.io/k6/metrics"
+)
+
+type Metrics struct {
+	TimeSeriesMeasurements  *metrics.Metric
+	TimeSeriesBatchDuration *metrics.Metric
+	GridFSBytesSent         *metrics.Metric
+	GridFSBytesReceived     *metrics.Metric
+	ServerConnections       *metrics.Metric
+	ServerOpcounters        *metrics.Metric
+	ServerCacheBytes        *metrics.Metric
+	ServerCacheUsage        *metrics.Metric
+	ServerQueueLength       *metrics.Metric
+	ServerReplicationLag    *metrics.Metric
+	CommandDuration         *metrics.Metric
+	PoolCheckoutWait        *metrics.Metric
+	PoolConnectionsCreated  *metrics.Metric
+	PoolConnectionsClosed   *metrics.Metric
+	ReplayDuration          *metrics.Metric
+	OperationDuration       *metrics.Metric
+	Retries                 *metrics.Metric
+	RetryDuration           *metrics.Metric
+	Errors                  *metrics.Metric
+	ProxyConnectDuration    *metrics.Metric
+	ConnectDuration         *metrics.Metric
+	TLSHandshakeDuration    *metrics.Metric
+	AuthDuration            *metrics.Metric
+	ServerSelectionDuration *metrics.Metric
+	ChurnConnectDuration    *metrics.Metric
+	ChurnFirstOpDuration    *metrics.Metric
+	ChurnCloseDuration      *metrics.Metric
+}
+
+func RegisterMetrics(registry *metrics.Registry) (*Metrics, error) {
+	var err error
+	m := &Metrics{}
+	if m.TimeSeriesMeasurements, err = registry.NewMetric("mongo_timeseries_measurements", metrics.Counter); err != nil {
+		return nil, err
+	}
+	if m.TimeSeriesBatchDuration, err = registry.NewMetric("mongo_timeseries_batch_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.GridFSBytesSent, err = registry.NewMetric("mongo_gridfs_bytes_sent", metrics.Counter, metrics.Data); err != nil {
+		return nil, err
+	}
+	if m.GridFSBytesReceived, err = registry.NewMetric("mongo_gridfs_bytes_received", metrics.Counter, metrics.Data); err != nil {
+		return nil, err
+	}
+	if m.ServerConnections, err = registry.NewMetric("mongo_server_connections_current", metrics.Gauge); err != nil {
+		return nil, err
+	}
+	if m.ServerOpcounters, err = registry.NewMetric("mongo_server_opcounters", metrics.Gauge); err != nil {
+		return nil, err
+	}
+	if m.ServerCacheBytes, err = registry.NewMetric("mongo_server_wiredtiger_cache_bytes", metrics.Gauge, metrics.Data); err != nil {
+		return nil, err
+	}
+	if m.ServerCacheUsage, err = registry.NewMetric("mongo_server_wiredtiger_cache_usage", metrics.Gauge); err != nil {
+		return nil, err
+	}
+	if m.ServerQueueLength, err = registry.NewMetric("mongo_server_queue_length", metrics.Gauge); err != nil {
+		return nil, err
+	}
+	if m.ServerReplicationLag, err = registry.NewMetric("mongo_server_replication_lag", metrics.Gauge, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.CommandDuration, err = registry.NewMetric("mongo_command_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.PoolCheckoutWait, err = registry.NewMetric("mongo_pool_checkout_wait", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.PoolConnectionsCreated, err = registry.NewMetric("mongo_pool_connections_created", metrics.Counter); err != nil {
+		return nil, err
+	}
+	if m.PoolConnectionsClosed, err = registry.NewMetric("mongo_pool_connections_closed", metrics.Counter); err != nil {
+		return nil, err
+	}
+	if m.ReplayDuration, err = registry.NewMetric("mongo_replay_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.OperationDuration, err = registry.NewMetric("mongo_op_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.Retries, err = registry.NewMetric("mongo_retries", metrics.Counter); err != nil {
+		return nil, err
+	}
+	if m.RetryDuration, err = registry.NewMetric("mongo_retry_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.Errors, err = registry.NewMetric("mongo_errors", metrics.Counter); err != nil {
+		return nil, err
+	}
+	if m.ProxyConnectDuration, err = registry.NewMetric("mongo_proxy_connect_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.ConnectDuration, err = registry.NewMetric("mongo_connect_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.TLSHandshakeDuration, err = registry.NewMetric("mongo_tls_handshake_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.AuthDuration, err = registry.NewMetric("mongo_auth_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.ServerSelectionDuration, err = registry.NewMetric("mongo_server_selection_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.ChurnConnectDuration, err = registry.NewMetric("mongo_churn_connect_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.ChurnFirstOpDuration, err = registry.NewMetric("mongo_churn_first_op_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	if m.ChurnCloseDuration, err = registry.NewMetric("mongo_churn_close_duration", metrics.Trend, metrics.Time); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reporter pushes samples for a single VU. Samples reported from the init context, where there
+// is no VU state, or through a nil Reporter are dropped.
+type Reporter struct {
+	vu      k6modules.VU
+	metrics *Metrics
+	dialer  *dialer
+	// selecting holds the start of the VU's current operation, in Unix nanoseconds, until its
+	// first connection checkout ends server selection.
+	selecting int64
+	// pools tracks the client's servers and their established connections for warmup.
+	pools *pools
+}
+
+func NewReporter(vu k6modules.VU, m *Metrics) *Reporter {
+	r := &Reporter{
+		vu,
+		m,
+		newDialer(m),
+		0,
+		newPools(),
+	}
+	if vu == nil {
+		r.dialer.bind(&net.Dialer{})
+	}
+	return r
+}
+
+// bindDialer hands the VU's k6 dialer to the client's connections once the VU has state.
+func (r *Reporter) bindDialer() {
+	if r == nil || r.vu == nil {
+		return
+	}
+	if state := r.vu.State(); state != nil && state.Dialer != nil {
+		r.dialer.bind(state.Dialer)
+	}
+}
+
+func (r *Reporter) push(metric *metrics.Metric, value float64, tags map[string]string) {
+	if s := r.sink(); s != nil {
+		s.push(metric, value, tags)
+	}
+}
+
+// sampleSink is where a VU's samples go, captured with the tags in effect at capture time so
+// that background goroutines can keep pushing after the call that started them has returned.
+type sampleSink struct {
+	ctx     context.Context
+	samples chan<- metrics.SampleContainer
+	tags    metrics.TagsAndMeta
+	logger  logrus.FieldLogger
+}
+
+func (r *Reporter) sink() *sampleSink {
+	if r == nil || r.vu == nil {
+		return nil
+	}
+	state := r.vu.State()
+	if state == nil {
+		return nil
+	}
+	r.bindDialer()
+	return &sampleSink{
+		r.vu.Context(),
+		state.Samples,
+		state.Tags.GetCurrentValues(),
+		state.Logger,
+	}
+}
+
+func (s *sampleSink) push(metric *metrics.Metric, value float64, tags map[string]string) {
+	tagSet := s.tags.Tags
+	for k, v := range tags {
+		tagSet = tagSet.With(k, v)
+	}
+	metrics.PushIfNotDone(s.ctx, s.samples, metrics.Sample{
+		TimeSeries: metrics.TimeSeries{Metric: metric, Tags: tagSet},
+		Time:       time.Now(),
+		Value:      value,
+		Metadata:   s.tags.Metadata,
+	})
+}
+
+func (r *Reporter) timeSeriesBatch(collection string, measurements int, elapsed time.Duration) {
+	if r == nil {
+		return
+	}
+	tags := map[string]string{"collection": collection}
+	r.push(r.metrics.TimeSeriesMeasurements, float64(measurements), tags)
+	r.push(r.metrics.TimeSeriesBatchDuration, durationMs(elapsed), tags)
+}
+
+func (r *Reporter) gridFSBytes(bucket, operation string, sent, received int) {
+	if r == nil {
+		return
+	}
+	tags := map[string]string{"bucket": bucket, "operation": operation}
+	if sent > 0 {
+		r.push(r.metrics.GridFSBytesSent, float64(sent), tags)
+	}
+	if received > 0 {
+		r.push(r.metrics.GridFSBytesReceived, float64(received), tags)
+	}
+}
+
+func (r *Reporter) commandFinished(command, host, status string, elapsed time.Duration) {
+	if r == nil {
+		return
+	}
+	tags := map[string]string{"command": command, "host": host, "status": status}
+	r.push(r.metrics.CommandDuration, durationMs(elapsed), tags)
+}
+
+func (r *Reporter) poolCheckout(host string, succeeded bool, wait time.Duration) {
+	if r == nil {
+		return
+	}
+	status := "succeeded"
+	if !succeeded {
+		status = "failed"
+	}
+	r.push(r.metrics.PoolCheckoutWait, durationMs(wait), map[string]string{"host": host, "status": status})
+}
+
+func (r *Reporter) poolConnection(created bool, host, reason string) {
+	if r == nil {
+		return
+	}
+	if created {
+		r.push(r.metrics.PoolConnectionsCreated, 1, map[string]string{"host": host})
+		return
+	}
+	r.push(r.metrics.PoolConnectionsClosed, 1, map[string]string{"host": host, "reason": reason})
+}
+
+func (r *Reporter) replayOperation(op, collection, shape string, shapes QueryShapeOptions, err error, elapsed time.Duration) {
+	if r == nil {
+		return
+	}
+	tags := map[string]string{"op": op, "collection": collection}
+	if shape != "" {
+		shapes.tag(tags, shape)
+	}
+	r.failure(tags, err)
+	r.push(r.metrics.ReplayDuration, durationMs(elapsed), tags)
+	r.flushConnections()
+}
+
+func (r *Reporter) operation(tags map[string]string, err error, elapsed time.Duration) {
+	if r == nil {
+		return
+	}
+	r.failure(tags, err)
+	r.push(r.metrics.OperationDuration, durationMs(elapsed), tags)
+	r.flushConnections()
+}
+
+// failure sets the status tag of an operation and, when err is not nil, its error_class tag,
+// counting the error in mongo_errors.
+func (r *Reporter) failure(tags map[string]string, err error) {
+	if err == nil {
+		tags["status"] = "succeeded"
+		return
+	}
+	tags["status"] = "failed"
+	tags["error_class"] = classifyError(err)
+	r.push(r.metrics.Errors, 1, tags)
+}
+
+func (r *Reporter) retry(op, collection string) {
+	if r == nil {
+		return
+	}
+	r.push(r.metrics.Retries, 1, map[string]string{"op": op, "collection": collection})
+}
+
+// retried reports the time an operation spent retrying, from its first failure to its final
+// outcome.
+func (r *Reporter) retried(op, collection string, succeeded bool, elapsed time.Duration) {
+	if r == nil {
+		return
+	}
+	tags := map[string]string{"op": op, "collection": collection, "status": "succeeded"}
+	if !succeeded {
+		tags["status"] = "failed"
+	}
+	r.push(r.metrics.RetryDuration, durationMs(elapsed), tags)
+}
+
+// flushConnections reports the bytes exchanged with each host since the last flush as k6's
+// built-in data_sent and data_received metrics, along with the connection measurements taken on
+// driver goroutines since then.
+func (r *Reporter) flushConnections() {
+	if r == nil || r.vu == nil || r.vu.State() == nil {
+		return
+	}
+	builtin := r.vu.State().BuiltinMetrics
+	r.dialer.traffic.drain(func(host string, sent, received int64) {
+		tags := map[string]string{"host": host}
+		if sent > 0 {
+			r.push(builtin.DataSent, float64(sent), tags)
+		}
+		if received > 0 {
+			r.push(builtin.DataReceived, float64(received), tags)
+		}
+	})
+	for _, t := range r.dialer.drainTimings() {
+		r.push(t.metric, durationMs(t.elapsed), t.tags)
+	}
+}
+
+// startSelection marks the start of an operation for mongo_server_selection_duration.
+func (r *Reporter) startSelection() {
+	if r == nil {
+		return
+	}
+	atomic.StoreInt64(&r.selecting, time.Now().UnixNano())
+}
+
+// selected reports the server selection of the pending operation, if any, as ended by a
+// connection checkout from host.
+func (r *Reporter) selected(host string) {
+	if r == nil {
+		return
+	}
+	started := atomic.SwapInt64(&r.selecting, 0)
+	if started == 0 {
+		return
+	}
+	elapsed := time.Since(time.Unix(0, started))
+	r.push(r.metrics.ServerSelectionDuration, durationMs(elapsed), map[string]string{"host": host})
+}
+
+// churnPhase reports one phase of a churn cycle, in milliseconds.
+func (r *Reporter) churnPhase(metric *metrics.Metric, ms float64, tags map[string]string, err error) {
+	if r == nil {
+		return
+	}
+	phaseTags := map[string]string{"status": status(err)}
+	for k, v := range tags {
+		phaseTags[k] = v
+	}
+	r.push(metric, ms, phaseTags)
+}
+
+// status is the status tag value of an operation that returned err.
+func status(err error) string {
+	if err != nil {
+		return "failed"
+	}
+	return "succeeded"
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
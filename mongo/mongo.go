@@ -2,17 +2,75 @@ package mongo
 
 import (
 	"context"
+	"errors"
+
+	"github.com/dop251/goja"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
 )
 
-func NewMongoDBConnection(ctx context.Context, uri string) (*mongo.Client, error) {
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+type ClientOptions struct {
+	// Proxy routes every connection through a proxy given as socks5://[user:password@]host:port
+	// or http://[user:password@]host:port, the latter using HTTP CONNECT.
+	Proxy string `js:"proxy"`
+	// MaxPoolSize caps the connections per server, overriding the connection string.
+	MaxPoolSize uint64 `js:"maxPoolSize"`
+	// MinPoolSize is the number of connections per server the driver keeps open, overriding the
+	// connection string. warmup waits for these connections to be established.
+	MinPoolSize uint64 `js:"minPoolSize"`
+	// TimeTLSHandshake lets the dialer perform the TLS handshake in place of the driver, so that
+	// mongo_tls_handshake_duration and mongo_auth_duration can be reported for TLS connections.
+	// The driver's OCSP endpoint checks are then skipped, so it is off by default.
+	TimeTLSHandshake bool `js:"timeTLSHandshake"`
+	// Warmup, when set, fills the pools of the VU's client before its first iteration.
+	Warmup *WarmupOptions `js:"warmup"`
+}
+
+func NewMongoDBConnection(ctx context.Context, uri string, reporter *Reporter, clientOpts ClientOptions) (*mongo.Client, error) {
+	opts := newMonitor(reporter).apply(options.Client().ApplyURI(uri))
+	if clientOpts.MaxPoolSize > 0 {
+		opts.SetMaxPoolSize(clientOpts.MaxPoolSize)
+	}
+	if clientOpts.MinPoolSize > 0 {
+		opts.SetMinPoolSize(clientOpts.MinPoolSize)
+	}
+	if reporter != nil {
+		if opts.MinPoolSize != nil {
+			reporter.pools.minPoolSize = *opts.MinPoolSize
+		}
+		if clientOpts.Warmup != nil {
+			if _, err := reporter.pools.target(clientOpts.Warmup.Connections); err != nil {
+				return nil, err
+			}
+		}
+		if clientOpts.Proxy != "" {
+			proxy, err := parseProxy(clientOpts.Proxy)
+			if err != nil {
+				return nil, err
+			}
+			reporter.dialer.proxy = proxy
+		}
+		if opts.TLSConfig != nil {
+			if clientOpts.TimeTLSHandshake {
+				// The dialer takes over TLS to time the handshake. The driver's OCSP checks are
+				// not performed on these connections.
+				reporter.dialer.tls = opts.TLSConfig
+				opts.TLSConfig = nil
+			} else {
+				reporter.dialer.encrypted = true
+			}
+		}
+		opts.SetDialer(reporter.dialer)
+	}
+	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
+	if clientOpts.Warmup != nil {
+		reporter.warmupOnFirstIteration(*clientOpts.Warmup)
+	}
 	return client, nil
 }
 
@@ -31,12 +89,18 @@ type MongoDB interface {
 type mongodb struct {
 	MongoClient *mongo.Client
 	Collection  MongoCollection
+	Reporter    *Reporter
+	shapes      QueryShapeOptions
+	retry       *retryPolicy
 }
 
-func NewMongoDB(mongoClient *mongo.Client, collection MongoCollection) *mongodb {
+func NewMongoDB(mongoClient *mongo.Client, collection MongoCollection, reporter *Reporter) *mongodb {
 	return &mongodb{
 		mongoClient,
 		collection,
+		reporter,
+		QueryShapeOptions{},
+		nil,
 	}
 }
 
@@ -44,100 +108,243 @@ func NewCollection(mongoClient *mongo.Client, database, collection string) Mongo
 	return mongoClient.Database(database).Collection(collection)
 }
 
-func (m *mongodb) FindOne(filter interface{}, opts ...*options.FindOneOptions) (interface{}, error) {
+func (m *mongodb) FindOne(filter interface{}, params goja.Value) (interface{}, error) {
 	ctx := context.TODO()
 	var result interface{}
-	r := m.Collection.FindOne(ctx, filter, opts...)
-	if r.Err() != nil {
-		return nil, r.Err()
+	opts := options.FindOne()
+	p, err := m.params(params, opts)
+	if err != nil {
+		return nil, err
 	}
-	err := r.Decode(result)
+	collection, err := m.collection(p)
+	if err != nil {
+		return nil, err
+	}
+	var r *mongo.SingleResult
+	err = m.run("findOne", filter, p, func() error {
+		r = collection.FindOne(ctx, filter, opts)
+		if errors.Is(r.Err(), mongo.ErrNoDocuments) {
+			// A filter matching nothing is a miss, as in workloads, not a failure.
+			r = nil
+			return nil
+		}
+		return r.Err()
+	})
+	if err != nil || r == nil {
+		return nil, err
+	}
+	err = r.Decode(&result)
 	if err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-func (m *mongodb) Find(filter interface{}, opts ...*options.FindOptions) (interface{}, error) {
+func (m *mongodb) Find(filter interface{}, params goja.Value) (interface{}, error) {
 	ctx := context.TODO()
-	var result interface{}
-	cursor, err := m.Collection.Find(ctx, filter, opts...)
+	var result []interface{}
+	opts := options.Find()
+	p, err := m.params(params, opts)
 	if err != nil {
 		return nil, err
 	}
-	err = cursor.All(ctx, result)
+	collection, err := m.collection(p)
 	if err != nil {
 		return nil, err
 	}
-	return result, err
+	// The cursor is drained within the operation so that its getMore round trips are timed and
+	// retried along with the find itself.
+	err = m.run("find", filter, p, func() error {
+		cursor, err := collection.Find(ctx, filter, opts)
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, &result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
-func (m *mongodb) InsertOne(document interface{}, opts ...*options.InsertOneOptions) (*primitive.ObjectID, error) {
+func (m *mongodb) InsertOne(document interface{}, params goja.Value) (*primitive.ObjectID, error) {
 	ctx := context.TODO()
-	result, err := m.Collection.InsertOne(ctx, document, opts...)
+	opts := options.InsertOne()
+	p, err := m.params(params, opts)
+	if err != nil {
+		return nil, err
+	}
+	collection, err := m.collection(p)
 	if err != nil {
 		return nil, err
 	}
+	var result *mongo.InsertOneResult
+	err = m.run("insertOne", nil, p, func() (err error) {
+		result, err = collection.InsertOne(ctx, document, opts)
+		if unacknowledged(err) {
+			result = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil || result == nil {
+		return nil, err
+	}
 	id := result.InsertedID.(primitive.ObjectID)
 	return &id, nil
 }
 
-func (m *mongodb) InsertMany(documents []interface{}, opts ...*options.InsertManyOptions) ([]primitive.ObjectID, error) {
+func (m *mongodb) InsertMany(documents []interface{}, params goja.Value) ([]primitive.ObjectID, error) {
 	var insertedIds []primitive.ObjectID
 	ctx := context.TODO()
-	result, err := m.Collection.InsertMany(ctx, documents)
+	opts := options.InsertMany()
+	p, err := m.params(params, opts)
 	if err != nil {
 		return nil, err
 	}
+	collection, err := m.collection(p)
+	if err != nil {
+		return nil, err
+	}
+	var result *mongo.InsertManyResult
+	err = m.run("insertMany", nil, p, func() (err error) {
+		result, err = collection.InsertMany(ctx, documents, opts)
+		if unacknowledged(err) {
+			result = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil || result == nil {
+		return nil, err
+	}
 	for _, ids := range result.InsertedIDs {
 		insertedIds = append(insertedIds, ids.(primitive.ObjectID))
 	}
 	return insertedIds, nil
 }
 
-func (m *mongodb) UpdateOne(filter interface{}, update interface{}, opts ...*options.UpdateOptions) (bool, error) {
+func (m *mongodb) UpdateOne(filter interface{}, update interface{}, params goja.Value) (bool, error) {
 	ctx := context.TODO()
-	result, err := m.Collection.UpdateOne(ctx, filter, update, opts...)
+	opts := options.Update()
+	p, err := m.params(params, opts)
+	if err != nil {
+		return false, err
+	}
+	collection, err := m.collection(p)
 	if err != nil {
 		return false, err
 	}
+	var result *mongo.UpdateResult
+	err = m.run("updateOne", filter, p, func() (err error) {
+		result, err = collection.UpdateOne(ctx, filter, update, opts)
+		if unacknowledged(err) {
+			result = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil || result == nil {
+		return false, err
+	}
 	return result.ModifiedCount > 0, nil
 }
 
-func (m *mongodb) UpdateMany(filter interface{}, update interface{}, opts ...*options.UpdateOptions) (int64, error) {
+func (m *mongodb) UpdateMany(filter interface{}, update interface{}, params goja.Value) (int64, error) {
 	ctx := context.TODO()
-	result, err := m.Collection.UpdateMany(ctx, filter, update, opts...)
+	opts := options.Update()
+	p, err := m.params(params, opts)
 	if err != nil {
 		return 0, err
 	}
+	collection, err := m.collection(p)
+	if err != nil {
+		return 0, err
+	}
+	var result *mongo.UpdateResult
+	err = m.run("updateMany", filter, p, func() (err error) {
+		result, err = collection.UpdateMany(ctx, filter, update, opts)
+		if unacknowledged(err) {
+			result = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil || result == nil {
+		return 0, err
+	}
 	return result.ModifiedCount, nil
 
 }
 
-func (m *mongodb) DeleteOne(filter interface{}, opts ...*options.DeleteOptions) (bool, error) {
+func (m *mongodb) DeleteOne(filter interface{}, params goja.Value) (bool, error) {
 	ctx := context.TODO()
-	result, err := m.Collection.DeleteOne(ctx, filter, opts...)
+	opts := options.Delete()
+	p, err := m.params(params, opts)
 	if err != nil {
 		return false, err
 	}
+	collection, err := m.collection(p)
+	if err != nil {
+		return false, err
+	}
+	var result *mongo.DeleteResult
+	err = m.run("deleteOne", filter, p, func() (err error) {
+		result, err = collection.DeleteOne(ctx, filter, opts)
+		if unacknowledged(err) {
+			result = nil
+			return nil
+		}
+		return err
+	})
+	if err != nil || result == nil {
+		return false, err
+	}
 
 	return result.DeletedCount > 0, nil
 }
 
-func (m *mongodb) Aggregate(pipeline interface{}, opts ...*options.AggregateOptions) interface{} {
+// unacknowledged reports whether err is the driver's answer to a write with a w: 0 write concern.
+// Such writes were sent successfully but have no result, so the methods return null, false or 0.
+func unacknowledged(err error) bool {
+	return errors.Is(err, mongo.ErrUnacknowledgedWrite)
+}
+
+func (m *mongodb) Aggregate(pipeline interface{}, params goja.Value) interface{} {
 	ctx := context.TODO()
 	result := make([]interface{}, 10000)
-	cursor, err := m.Collection.Aggregate(ctx, pipeline, opts...)
+	opts := options.Aggregate()
+	p, err := m.params(params, opts)
 	if err != nil {
 		return err
 	}
-	if err = cursor.All(ctx, &result); err != nil {
+	collection, err := m.collection(p)
+	if err != nil {
+		return err
+	}
+	err = m.run("aggregate", pipeline, p, func() error {
+		cursor, err := collection.Aggregate(ctx, pipeline, opts)
+		if err != nil {
+			return err
+		}
+		return cursor.All(ctx, &result)
+	})
+	if err != nil {
 		return err
 	}
 	return result
 }
+func (m *mongodb) Drop() error {
+	ctx := context.TODO()
+	m.Reporter.bindDialer()
+	defer m.Reporter.flushConnections()
+	return m.Collection.Drop(ctx)
+}
+
 func (adapter *mongodb) Ping() error {
 	ctx := context.TODO()
+	adapter.Reporter.bindDialer()
+	defer adapter.Reporter.flushConnections()
 	return adapter.MongoClient.Ping(ctx, nil)
 }
 
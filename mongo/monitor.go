@@ -0,0 +1,136 @@
+package mongo
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// monitor bridges the driver's command and pool events into k6 metrics for one VU's client.
+// Samples are pushed with the context of the VU's current iteration, so events the driver emits
+// between iterations, such as connections closed by the pool's background maintenance, are
+// dropped.
+type monitor struct {
+	reporter *Reporter
+	pools    *pools
+
+	mu sync.Mutex
+	// checkouts holds the start times of pending connection checkouts per address. Pool events
+	// do not say which checkout they finish, so the oldest start is matched with the next finished
+	// checkout. That holds while a VU runs one operation at a time; when concurrent checkouts of a
+	// client finish out of order, such as one timing out while a later one succeeds, their waits
+	// are attributed to each other.
+	checkouts map[string][]time.Time
+}
+
+func newMonitor(reporter *Reporter) *monitor {
+	m := &monitor{
+		reporter:  reporter,
+		checkouts: make(map[string][]time.Time),
+	}
+	if reporter != nil {
+		m.pools = reporter.pools
+	}
+	return m
+}
+
+func (m *monitor) apply(opts *options.ClientOptions) *options.ClientOptions {
+	return opts.
+		SetMonitor(&event.CommandMonitor{
+			Started:   m.commandStarted,
+			Succeeded: m.commandSucceeded,
+			Failed:    m.commandFailed,
+		}).
+		SetPoolMonitor(&event.PoolMonitor{
+			Event: m.poolEvent,
+		}).
+		SetServerMonitor(&event.ServerMonitor{
+			TopologyDescriptionChanged: m.topologyChanged,
+		})
+}
+
+func (m *monitor) topologyChanged(e *event.TopologyDescriptionChangedEvent) {
+	m.pools.topologyChanged(e.NewDescription)
+}
+
+// commandStarted logs the command when k6 runs with --verbose. Rendering commands is too costly
+// to do unconditionally.
+func (m *monitor) commandStarted(_ context.Context, e *event.CommandStartedEvent) {
+	sink := m.reporter.sink()
+	if sink == nil || !debugEnabled(sink.logger) {
+		return
+	}
+	sink.logger.WithFields(logrus.Fields{
+		"command":    e.CommandName,
+		"database":   e.DatabaseName,
+		"connection": e.ConnectionID,
+		"request_id": e.RequestID,
+	}).Debug("mongo: " + e.Command.String())
+}
+
+func (m *monitor) commandSucceeded(_ context.Context, e *event.CommandSucceededEvent) {
+	m.reporter.commandFinished(e.CommandName, connectionHost(e.ConnectionID), "succeeded", e.Duration)
+}
+
+func (m *monitor) commandFailed(_ context.Context, e *event.CommandFailedEvent) {
+	m.reporter.commandFinished(e.CommandName, connectionHost(e.ConnectionID), "failed", e.Duration)
+	if sink := m.reporter.sink(); sink != nil && debugEnabled(sink.logger) {
+		sink.logger.WithFields(logrus.Fields{
+			"command":    e.CommandName,
+			"request_id": e.RequestID,
+		}).Debug("mongo: command failed: " + e.Failure)
+	}
+}
+
+func (m *monitor) poolEvent(e *event.PoolEvent) {
+	switch e.Type {
+	case event.GetStarted:
+		m.reporter.selected(e.Address)
+		m.mu.Lock()
+		m.checkouts[e.Address] = append(m.checkouts[e.Address], time.Now())
+		m.mu.Unlock()
+	case event.GetSucceeded, event.GetFailed:
+		m.mu.Lock()
+		pending := m.checkouts[e.Address]
+		if len(pending) == 0 {
+			m.mu.Unlock()
+			return
+		}
+		started := pending[0]
+		m.checkouts[e.Address] = pending[1:]
+		m.mu.Unlock()
+		m.reporter.poolCheckout(e.Address, e.Type == event.GetSucceeded, time.Since(started))
+	case event.ConnectionCreated:
+		m.reporter.poolConnection(true, e.Address, "")
+	case event.ConnectionReady:
+		m.pools.connectionReady(e.Address, e.ConnectionID)
+	case event.ConnectionClosed:
+		m.pools.connectionClosed(e.Address, e.ConnectionID)
+		m.reporter.poolConnection(false, e.Address, e.Reason)
+	}
+}
+
+// connectionHost strips the connection counter from a driver connection ID such as
+// "localhost:27017[-5]".
+func connectionHost(connectionID string) string {
+	if i := strings.IndexByte(connectionID, '['); i >= 0 {
+		return connectionID[:i]
+	}
+	return connectionID
+}
+
+func debugEnabled(logger logrus.FieldLogger) bool {
+	switch l := logger.(type) {
+	case *logrus.Entry:
+		return l.Logger.IsLevelEnabled(logrus.DebugLevel)
+	case *logrus.Logger:
+		return l.IsLevelEnabled(logrus.DebugLevel)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package mongo
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+const (
+	shapeModeOff  = "off"
+	shapeModeTag  = "tag"
+	shapeModeName = "name"
+
+	// otherShape replaces the shapes seen after the cardinality limit was reached.
+	otherShape = "other"
+
+	defaultMaxShapes = 100
+)
+
+type QueryShapeOptions struct {
+	// Mode is "tag" to add a shape tag to operation metrics, "name" to use the shape as the name
+	// tag, or "off".
+	Mode string `js:"mode"`
+	// MaxShapes caps the number of distinct shapes across all VUs. Later shapes are reported as
+	// "other".
+	MaxShapes int `js:"maxShapes"`
+	// Hash reports a short hash of the shape instead of the shape itself.
+	Hash bool `js:"hash"`
+}
+
+// knownShapes is shared by every VU so that the cardinality limit applies to the whole test.
+var knownShapes = struct {
+	sync.Mutex
+	shapes map[string]bool
+}{shapes: map[string]bool{}}
+
+// admitShape reports whether shape may be used as a tag value without exceeding max distinct
+// shapes.
+func admitShape(shape string, max int) bool {
+	knownShapes.Lock()
+	defer knownShapes.Unlock()
+	if knownShapes.shapes[shape] {
+		return true
+	}
+	if len(knownShapes.shapes) >= max {
+		return false
+	}
+	knownShapes.shapes[shape] = true
+	return true
+}
+
+// withDefaults enables shape tags with the default cardinality limit where opts leaves them out.
+func (opts QueryShapeOptions) withDefaults() QueryShapeOptions {
+	if opts.Mode == "" {
+		opts.Mode = shapeModeTag
+	}
+	if opts.MaxShapes <= 0 {
+		opts.MaxShapes = defaultMaxShapes
+	}
+	return opts
+}
+
+// tag adds shape to tags as configured, hashed if requested and replaced by "other" once the
+// cardinality limit is reached.
+func (opts QueryShapeOptions) tag(tags map[string]string, shape string) {
+	if opts.Mode == "" || opts.Mode == shapeModeOff {
+		return
+	}
+	if opts.Hash {
+		sum := sha1.Sum([]byte(shape))
+		shape = hex.EncodeToString(sum[:6])
+	}
+	if !admitShape(shape, opts.MaxShapes) {
+		shape = otherShape
+	}
+	if opts.Mode == shapeModeName {
+		tags["name"] = shape
+	} else {
+		tags["shape"] = shape
+	}
+}
+
+// TagQueryShapes configures how the query shapes of this handle's operations are reported.
+func (m *mongodb) TagQueryShapes(opts QueryShapeOptions) {
+	m.shapes = opts.withDefaults()
+}
+
+// observe reports an operation of this handle. query is the operation's filter, or its pipeline
+// for aggregations, and is only fingerprinted when shape reporting is enabled. p, when not nil,
+// adds the tags of the call's parameters, including its custom tags and name.
+func (m *mongodb) observe(op string, query interface{}, p *callParams, began time.Time, err error) {
+	tags := map[string]string{"op": op, "collection": m.Collection.Name()}
+	if query != nil && m.shapes.Mode != "" && m.shapes.Mode != shapeModeOff {
+		if op == "aggregate" {
+			m.shapes.tag(tags, pipelineFingerprint(query))
+		} else {
+			m.shapes.tag(tags, fingerprint(query))
+		}
+	}
+	// The call's own tags come last so that an explicit name wins over the shape.
+	if p != nil {
+		for k, v := range p.tags {
+			tags[k] = v
+		}
+	}
+	m.Reporter.operation(tags, err, time.Since(began))
+}
@@ -0,0 +1,196 @@
+package mongo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
+	"go.mongodb.org/mongo-driver/tag"
+)
+
+// ReadPreferenceOptions is the object form of the readPreference parameter, which also accepts a
+// plain mode string.
+type ReadPreferenceOptions struct {
+	Mode                string              `js:"mode"`
+	TagSets             []map[string]string `js:"tagSets"`
+	MaxStalenessSeconds int64               `js:"maxStalenessSeconds"`
+	Hedge               *bool               `js:"hedge"`
+}
+
+type WriteConcernOptions struct {
+	// W is a number of nodes or a tag set name such as "majority".
+	W        interface{} `js:"w"`
+	J        *bool       `js:"j"`
+	WTimeout int64       `js:"wtimeout"`
+}
+
+// callParams are the parameters every collection method accepts besides the driver's own
+// options, which are read from the same object. tags holds the tags derived from the consistency
+// overrides as well as the call's own tags and name.
+type callParams struct {
+	collectionOpts *options.CollectionOptions
+	tags           map[string]string
+	retry          *retryPolicy
+}
+
+// params reads the trailing parameters object of a collection method. Driver options such as
+// sort or limit are exported into driverOpts, which may be nil for methods without any.
+func (m *mongodb) params(v goja.Value, driverOpts interface{}) (*callParams, error) {
+	p := &callParams{tags: map[string]string{}}
+	m.Reporter.bindDialer()
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return p, nil
+	}
+	if m.Reporter == nil || m.Reporter.vu == nil {
+		return nil, errors.New("call parameters require a VU")
+	}
+	rt := m.Reporter.vu.Runtime()
+	if driverOpts != nil {
+		if err := rt.ExportTo(v, driverOpts); err != nil {
+			return nil, err
+		}
+	}
+	obj := v.ToObject(rt)
+
+	if rp := obj.Get("readPreference"); rp != nil && !goja.IsUndefined(rp) {
+		pref, mode, err := parseReadPreference(rt, rp)
+		if err != nil {
+			return nil, err
+		}
+		p.collection().SetReadPreference(pref)
+		p.tags["read_preference"] = mode
+	}
+	if rc := obj.Get("readConcern"); rc != nil && !goja.IsUndefined(rc) {
+		level, err := readConcernLevel(rc)
+		if err != nil {
+			return nil, err
+		}
+		p.collection().SetReadConcern(readconcern.New(readconcern.Level(level)))
+		p.tags["read_concern"] = level
+	}
+	if wc := obj.Get("writeConcern"); wc != nil && !goja.IsUndefined(wc) {
+		var opts WriteConcernOptions
+		if err := rt.ExportTo(wc, &opts); err != nil {
+			return nil, err
+		}
+		concern, w, err := opts.toDriver()
+		if err != nil {
+			return nil, err
+		}
+		p.collection().SetWriteConcern(concern)
+		p.tags["write_concern"] = w
+	}
+	if t := obj.Get("tags"); t != nil && !goja.IsUndefined(t) {
+		var tags map[string]string
+		if err := rt.ExportTo(t, &tags); err != nil {
+			return nil, err
+		}
+		for k, v := range tags {
+			p.tags[k] = v
+		}
+	}
+	if name := obj.Get("name"); name != nil && !goja.IsUndefined(name) {
+		p.tags["name"] = name.String()
+	}
+	if r := obj.Get("retry"); r != nil && !goja.IsUndefined(r) {
+		var opts RetryOptions
+		if err := rt.ExportTo(r, &opts); err != nil {
+			return nil, err
+		}
+		policy, err := newRetryPolicy(opts)
+		if err != nil {
+			return nil, err
+		}
+		p.retry = policy
+	}
+	return p, nil
+}
+
+func (p *callParams) collection() *options.CollectionOptions {
+	if p.collectionOpts == nil {
+		p.collectionOpts = options.Collection()
+	}
+	return p.collectionOpts
+}
+
+// collection returns the handle's collection, cloned with the call's read preference and
+// concerns when it overrides any of them.
+func (m *mongodb) collection(p *callParams) (MongoCollection, error) {
+	if p.collectionOpts == nil {
+		return m.Collection, nil
+	}
+	return m.Collection.Clone(p.collectionOpts)
+}
+
+// parseReadPreference reads a readPreference parameter, either a mode string or a
+// ReadPreferenceOptions object, and also returns its mode for tagging.
+func parseReadPreference(rt *goja.Runtime, v goja.Value) (*readpref.ReadPref, string, error) {
+	var opts ReadPreferenceOptions
+	if s, ok := v.Export().(string); ok {
+		opts.Mode = s
+	} else if err := rt.ExportTo(v, &opts); err != nil {
+		return nil, "", err
+	}
+	pref, err := opts.toDriver()
+	if err != nil {
+		return nil, "", err
+	}
+	return pref, opts.Mode, nil
+}
+
+// readConcernLevel reads a readConcern parameter, either a level string or a {level} object.
+func readConcernLevel(v goja.Value) (string, error) {
+	if obj, ok := v.(*goja.Object); ok {
+		v = obj.Get("level")
+	}
+	if v != nil {
+		if level, ok := v.Export().(string); ok && level != "" {
+			return level, nil
+		}
+	}
+	return "", errors.New("readConcern must be a level string or an object with a level string")
+}
+
+func (o ReadPreferenceOptions) toDriver() (*readpref.ReadPref, error) {
+	var opts []readpref.Option
+	if len(o.TagSets) > 0 {
+		opts = append(opts, readpref.WithTagSets(tag.NewTagSetsFromMaps(o.TagSets)...))
+	}
+	if o.MaxStalenessSeconds > 0 {
+		opts = append(opts, readpref.WithMaxStaleness(time.Duration(o.MaxStalenessSeconds)*time.Second))
+	}
+	if o.Hedge != nil {
+		opts = append(opts, readpref.WithHedgeEnabled(*o.Hedge))
+	}
+	return readPreference(o.Mode, opts...)
+}
+
+// toDriver also returns w as a string for tagging.
+func (o WriteConcernOptions) toDriver() (*writeconcern.WriteConcern, string, error) {
+	wc := &writeconcern.WriteConcern{Journal: o.J}
+	if o.WTimeout > 0 {
+		wc.WTimeout = time.Duration(o.WTimeout) * time.Millisecond
+	}
+	var w string
+	switch v := o.W.(type) {
+	case nil:
+	case int64:
+		wc.W = int(v)
+		w = strconv.FormatInt(v, 10)
+	case float64:
+		wc.W = int(v)
+		w = strconv.Itoa(int(v))
+	case string:
+		wc.W = v
+		w = v
+	default:
+		return nil, "", fmt.Errorf("invalid write concern w %v", v)
+	}
+	return wc, w, nil
+}
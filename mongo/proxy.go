@@ -0,0 +1,136 @@
+package mongo
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.k6.io/k6/metrics"
+	"golang.org/x/net/proxy"
+)
+
+const (
+	proxySOCKS5 = "socks5"
+	proxyHTTP   = "http"
+)
+
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case proxySOCKS5, proxyHTTP:
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, expected socks5 or http", u.Scheme)
+	}
+	if u.Port() == "" {
+		return nil, errors.New("proxy address must include a port")
+	}
+	return u, nil
+}
+
+// dialProxy opens a tunnel to address through the proxy and records how long establishing it
+// took, from dialing the proxy to the end of the proxy's handshake.
+func (d *dialer) dialProxy(ctx context.Context, network, address string) (net.Conn, error) {
+	began := time.Now()
+	var (
+		conn net.Conn
+		err  error
+	)
+	if d.proxy.Scheme == proxySOCKS5 {
+		conn, err = d.dialSOCKS5(ctx, network, address)
+	} else {
+		conn, err = d.dialHTTPConnect(ctx, network, address)
+	}
+	d.record(func(m *Metrics) *metrics.Metric { return m.ProxyConnectDuration }, time.Since(began), map[string]string{
+		"host":   address,
+		"proxy":  d.proxy.Scheme,
+		"status": status(err),
+	})
+	return conn, err
+}
+
+func (d *dialer) dialSOCKS5(ctx context.Context, network, address string) (net.Conn, error) {
+	var auth *proxy.Auth
+	if d.proxy.User != nil {
+		password, _ := d.proxy.User.Password()
+		auth = &proxy.Auth{User: d.proxy.User.Username(), Password: password}
+	}
+	socks, err := proxy.SOCKS5("tcp", d.proxy.Host, auth, forwardDialer{d})
+	if err != nil {
+		return nil, err
+	}
+	return socks.(proxy.ContextDialer).DialContext(ctx, network, address)
+}
+
+func (d *dialer) dialHTTPConnect(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := d.dialDirect(ctx, network, d.proxy.Host)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if d.proxy.User != nil {
+		password, _ := d.proxy.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.proxy.User.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+	if err = req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("proxy refused connection to %s: %s", address, resp.Status)
+	}
+	if br.Buffered() > 0 {
+		return &bufferedConn{conn, br}, nil
+	}
+	return conn, nil
+}
+
+// forwardDialer lets the SOCKS5 client reach the proxy through the bound dialer.
+type forwardDialer struct {
+	d *dialer
+}
+
+func (f forwardDialer) Dial(network, address string) (net.Conn, error) {
+	return f.d.dialDirect(context.Background(), network, address)
+}
+
+func (f forwardDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f.d.dialDirect(ctx, network, address)
+}
+
+// bufferedConn serves the bytes the proxy sent after its response before reading from the
+// connection again.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,285 @@
+package mongo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	recordSourceProfile = "profile"
+	recordSourceLog     = "log"
+)
+
+type RecordOptions struct {
+	// Source is "profile" to read this database's system.profile collection or "log" to read a
+	// mongod JSON log file from LogPath.
+	Source  string `js:"source"`
+	LogPath string `js:"logPath"`
+	// Namespaces limits the recording to these "database.collection" namespaces.
+	Namespaces []string  `js:"namespaces"`
+	Since      time.Time `js:"since"`
+	Until      time.Time `js:"until"`
+	Limit      int       `js:"limit"`
+}
+
+type WorkloadSummary struct {
+	Operations int            `js:"operations"`
+	Mix        map[string]int `js:"mix"`
+	DurationMs float64        `js:"durationMs"`
+}
+
+// capturedOperation is a command as found in the profiler or the log, before it is converted
+// into workload operations.
+type capturedOperation struct {
+	ts        time.Time
+	namespace string
+	opType    string
+	command   bson.Raw
+}
+
+// RecordWorkload converts operations captured by the profiler or written to a mongod log into a
+// workload file for ReplayWorkload. The file holds one Extended JSON document per line with the
+// operation's offset from the first recorded operation in offsetMs, its type in op, its
+// namespace in ns and the arguments needed to reissue it.
+func (d *database) RecordWorkload(path string, opts RecordOptions) (*WorkloadSummary, error) {
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	var (
+		captured []capturedOperation
+		err      error
+	)
+	switch opts.Source {
+	case "", recordSourceProfile:
+		captured, err = d.readProfile(context.TODO(), opts)
+	case recordSourceLog:
+		captured, err = readLogFile(opts)
+	default:
+		err = errors.New("unknown workload source " + opts.Source)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(captured, func(i, j int) bool { return captured[i].ts.Before(captured[j].ts) })
+
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	summary := &WorkloadSummary{Mix: map[string]int{}}
+	var first time.Time
+record:
+	for _, c := range captured {
+		for _, op := range workloadOperations(c) {
+			if opts.Limit > 0 && summary.Operations >= opts.Limit {
+				break record
+			}
+			if summary.Operations == 0 {
+				first = c.ts
+			}
+			offset := durationMs(c.ts.Sub(first))
+			line, err := bson.MarshalExtJSON(append(bson.D{{Key: "offsetMs", Value: offset}}, op...), false, false)
+			if err != nil {
+				return nil, err
+			}
+			if _, err = w.Write(append(line, '\n')); err != nil {
+				return nil, err
+			}
+			summary.Operations++
+			summary.Mix[op[0].Value.(string)]++
+			summary.DurationMs = offset
+		}
+	}
+	return summary, w.Flush()
+}
+
+func (d *database) readProfile(ctx context.Context, opts RecordOptions) ([]capturedOperation, error) {
+	filter := bson.D{}
+	if len(opts.Namespaces) > 0 {
+		filter = append(filter, bson.E{Key: "ns", Value: bson.D{{Key: "$in", Value: opts.Namespaces}}})
+	}
+	ts := bson.D{}
+	if !opts.Since.IsZero() {
+		ts = append(ts, bson.E{Key: "$gte", Value: opts.Since})
+	}
+	if !opts.Until.IsZero() {
+		ts = append(ts, bson.E{Key: "$lt", Value: opts.Until})
+	}
+	if len(ts) > 0 {
+		filter = append(filter, bson.E{Key: "ts", Value: ts})
+	}
+	findOpts := options.Find().SetSort(bson.D{{Key: "ts", Value: 1}})
+	cursor, err := d.Database.Collection("system.profile").Find(ctx, filter, findOpts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	var captured []capturedOperation
+	for cursor.Next(ctx) {
+		doc := cursor.Current
+		command, ok := doc.Lookup("command").DocumentOK()
+		if !ok {
+			continue
+		}
+		ts, ok := doc.Lookup("ts").TimeOK()
+		if !ok {
+			continue
+		}
+		ns, _ := doc.Lookup("ns").StringValueOK()
+		opType, _ := doc.Lookup("op").StringValueOK()
+		captured = append(captured, capturedOperation{ts, ns, opType, command})
+	}
+	return captured, cursor.Err()
+}
+
+// readLogFile reads the slow query entries of a structured (4.4+) mongod log.
+func readLogFile(opts RecordOptions) ([]capturedOperation, error) {
+	file, err := os.Open(opts.LogPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	namespaces := make(map[string]bool, len(opts.Namespaces))
+	for _, ns := range opts.Namespaces {
+		namespaces[ns] = true
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxSeedLineSize)
+	var captured []capturedOperation
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !strings.Contains(string(line), `"command"`) {
+			continue
+		}
+		var entry bson.D
+		if bson.UnmarshalExtJSON(line, false, &entry) != nil {
+			continue
+		}
+		raw, err := bson.Marshal(entry)
+		if err != nil {
+			continue
+		}
+		doc := bson.Raw(raw)
+		command, ok := doc.Lookup("attr", "command").DocumentOK()
+		if !ok {
+			continue
+		}
+		ts, ok := doc.Lookup("t").TimeOK()
+		if !ok {
+			continue
+		}
+		ns, _ := doc.Lookup("attr", "ns").StringValueOK()
+		if len(namespaces) > 0 && !namespaces[ns] {
+			continue
+		}
+		if (!opts.Since.IsZero() && ts.Before(opts.Since)) || (!opts.Until.IsZero() && !ts.Before(opts.Until)) {
+			continue
+		}
+		opType, _ := doc.Lookup("attr", "type").StringValueOK()
+		captured = append(captured, capturedOperation{ts, ns, opType, command})
+	}
+	return captured, scanner.Err()
+}
+
+// workloadOperations converts a captured command into workload operations. Batched update and
+// delete commands expand into one operation per statement. Commands that cannot be replayed,
+// such as getMore or administrative commands, produce none.
+func workloadOperations(c capturedOperation) []bson.D {
+	elems, err := c.command.Elements()
+	if err != nil || len(elems) == 0 {
+		return nil
+	}
+	ns := bson.E{Key: "ns", Value: c.namespace}
+	switch elems[0].Key() {
+	case "find":
+		op := bson.D{{Key: "op", Value: "find"}, ns}
+		return []bson.D{appendFields(op, c.command, "filter", "sort", "projection", "limit", "skip")}
+	case "aggregate":
+		op := bson.D{{Key: "op", Value: "aggregate"}, ns}
+		return []bson.D{appendFields(op, c.command, "pipeline")}
+	case "insert":
+		op := bson.D{{Key: "op", Value: "insert"}, ns}
+		if docs, ok := c.command.Lookup("documents").ArrayOK(); ok {
+			return []bson.D{append(op, bson.E{Key: "documents", Value: docs})}
+		}
+		// The profiler leaves the documents out of insert commands, so the replay has to supply them.
+		return []bson.D{append(op, bson.E{Key: "n", Value: 1})}
+	case "update":
+		return statements(c, "updates", "update", "q", "u", "multi", "upsert")
+	case "delete":
+		return statements(c, "deletes", "delete", "q", "limit")
+	}
+	// Older profiler entries record single update and remove statements without the command.
+	switch c.opType {
+	case "update":
+		return []bson.D{statement(c.namespace, "update", c.command, "q", "u", "multi", "upsert")}
+	case "remove":
+		return []bson.D{statement(c.namespace, "delete", c.command, "q", "limit")}
+	}
+	return nil
+}
+
+func statements(c capturedOperation, field, op string, keys ...string) []bson.D {
+	arr, ok := c.command.Lookup(field).ArrayOK()
+	if !ok {
+		return nil
+	}
+	values, err := arr.Values()
+	if err != nil {
+		return nil
+	}
+	ops := make([]bson.D, 0, len(values))
+	for _, v := range values {
+		if doc, ok := v.DocumentOK(); ok {
+			ops = append(ops, statement(c.namespace, op, doc, keys...))
+		}
+	}
+	return ops
+}
+
+// statement builds an update or delete operation from a statement, renaming the wire protocol's
+// q and u to filter and update.
+func statement(namespace, op string, doc bson.Raw, keys ...string) bson.D {
+	result := bson.D{{Key: "op", Value: op}, {Key: "ns", Value: namespace}}
+	for _, key := range keys {
+		value, err := doc.LookupErr(key)
+		if err != nil {
+			continue
+		}
+		name := key
+		switch key {
+		case "q":
+			name = "filter"
+		case "u":
+			name = "update"
+		case "limit":
+			// A delete statement's limit is 0 for deleteMany and 1 for deleteOne.
+			name = "multi"
+			if n, ok := value.AsInt64OK(); ok {
+				result = append(result, bson.E{Key: name, Value: n == 0})
+			}
+			continue
+		}
+		result = append(result, bson.E{Key: name, Value: value})
+	}
+	return result
+}
+
+func appendFields(op bson.D, doc bson.Raw, keys ...string) bson.D {
+	for _, key := range keys {
+		if value, err := doc.LookupErr(key); err == nil && value.Type != bsontype.Null {
+			op = append(op, bson.E{Key: key, Value: value})
+		}
+	}
+	return op
+}
@@ -0,0 +1,364 @@
+package mongo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	k6modules "go.k6.io/k6/js/modules"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type ReplayOptions struct {
+	// Speed scales the recorded inter-arrival times: 2 replays twice as fast. Zero replays
+	// without waiting between operations; leaving it out keeps the recorded timing.
+	Speed *float64 `js:"speed"`
+	// Loop restarts the workload from the beginning until the VU is stopped.
+	Loop bool `js:"loop"`
+	// Mapping substitutes parameters: every field with a mapped name found in a recorded filter,
+	// update, pipeline or document gets the mapped value instead, or the result of calling it
+	// with the recorded value when it is a function. Inserts recorded without their documents
+	// insert a document built from the mapping.
+	Mapping map[string]goja.Value `js:"mapping"`
+	// QueryShapes configures the shape tag of mongo_replay_duration like tagQueryShapes does for
+	// collection operations. Shapes are tagged by default, up to the same cardinality limit.
+	QueryShapes QueryShapeOptions `js:"queryShapes"`
+}
+
+type ReplaySummary struct {
+	Operations int     `js:"operations"`
+	Errors     int     `js:"errors"`
+	DurationMs float64 `js:"durationMs"`
+}
+
+// workloadOperation is one line of a workload file written by RecordWorkload.
+type workloadOperation struct {
+	OffsetMs   float64       `bson:"offsetMs"`
+	Op         string        `bson:"op"`
+	Namespace  string        `bson:"ns"`
+	Filter     bson.RawValue `bson:"filter"`
+	Sort       bson.RawValue `bson:"sort"`
+	Projection bson.RawValue `bson:"projection"`
+	Limit      int64         `bson:"limit"`
+	Skip       int64         `bson:"skip"`
+	Pipeline   bson.RawValue `bson:"pipeline"`
+	Update     bson.RawValue `bson:"update"`
+	Documents  bson.RawValue `bson:"documents"`
+	Multi      bool          `bson:"multi"`
+	Upsert     bool          `bson:"upsert"`
+	N          int           `bson:"n"`
+}
+
+type replayer struct {
+	vu       k6modules.VU
+	reporter *Reporter
+	// collection resolves the collection an operation runs against from its recorded namespace.
+	collection func(namespace string) MongoCollection
+	mapping    map[string]goja.Value
+	shapes     QueryShapeOptions
+}
+
+// Replay reissues the operations of a recorded workload, the contents of a file written by
+// RecordWorkload, against target, which is either a collection handle that receives every
+// operation or a database handle that routes operations by their recorded collection name.
+// Every operation reports mongo_replay_duration tagged with its op, collection and query shape.
+// Failed operations are counted rather than stopping the replay, while a mapping function that
+// throws stops it with the error.
+func Replay(vu k6modules.VU, target interface{}, workload string, opts ReplayOptions) (*ReplaySummary, error) {
+	ops, err := parseWorkload(workload)
+	if err != nil {
+		return nil, err
+	}
+	r := &replayer{vu: vu, mapping: opts.Mapping, shapes: opts.QueryShapes.withDefaults()}
+	switch t := target.(type) {
+	case *mongodb:
+		r.reporter = t.Reporter
+		r.collection = func(string) MongoCollection { return t.Collection }
+	case *database:
+		r.reporter = t.Reporter
+		r.collection = func(namespace string) MongoCollection {
+			return t.Database.Collection(collectionName(namespace))
+		}
+	default:
+		return nil, errors.New("replay target must be a collection or database handle")
+	}
+	sink := r.reporter.sink()
+	if sink == nil {
+		return nil, errors.New("workloads can only be replayed from VU code, not the init context")
+	}
+	ctx := sink.ctx
+	speed := 1.0
+	if opts.Speed != nil {
+		speed = *opts.Speed
+	}
+
+	summary := &ReplaySummary{}
+	started := time.Now()
+	for {
+		passStarted := time.Now()
+		for _, op := range ops {
+			if speed > 0 {
+				due := passStarted.Add(time.Duration(op.OffsetMs / speed * float64(time.Millisecond)))
+				if err := sleepUntil(ctx, due); err != nil {
+					summary.DurationMs = durationMs(time.Since(started))
+					return summary, nil
+				}
+			} else if ctx.Err() != nil {
+				summary.DurationMs = durationMs(time.Since(started))
+				return summary, nil
+			}
+			err := r.execute(ctx, op)
+			var mapErr *mappingError
+			if errors.As(err, &mapErr) {
+				summary.DurationMs = durationMs(time.Since(started))
+				return summary, err
+			}
+			summary.Operations++
+			if err != nil {
+				summary.Errors++
+			}
+		}
+		if !opts.Loop || len(ops) == 0 {
+			break
+		}
+	}
+	summary.DurationMs = durationMs(time.Since(started))
+	return summary, nil
+}
+
+func parseWorkload(workload string) ([]workloadOperation, error) {
+	var ops []workloadOperation
+	scanner := bufio.NewScanner(strings.NewReader(workload))
+	scanner.Buffer(nil, maxSeedLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var op workloadOperation
+		if err := bson.UnmarshalExtJSON([]byte(text), false, &op); err != nil {
+			return nil, fmt.Errorf("workload line %d: %w", line, err)
+		}
+		ops = append(ops, op)
+	}
+	return ops, scanner.Err()
+}
+
+// replayValues are the documents of a recorded operation, decoded and mapped.
+type replayValues struct {
+	filter, sort, projection, pipeline, update, documents interface{}
+}
+
+func (r *replayer) decode(op workloadOperation) (v replayValues, err error) {
+	for _, field := range []struct {
+		dst *interface{}
+		raw bson.RawValue
+		def interface{}
+	}{
+		{&v.filter, op.Filter, bson.D{}},
+		{&v.sort, op.Sort, nil},
+		{&v.projection, op.Projection, nil},
+		{&v.pipeline, op.Pipeline, bson.A{}},
+		{&v.update, op.Update, nil},
+		{&v.documents, op.Documents, nil},
+	} {
+		if *field.dst, err = r.value(field.raw, field.def); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+func (r *replayer) execute(ctx context.Context, op workloadOperation) error {
+	collection := r.collection(op.Namespace)
+	values, err := r.decode(op)
+	if err != nil {
+		return err
+	}
+	filter := values.filter
+	var shape string
+	began := time.Now()
+	switch op.Op {
+	case "find":
+		shape = fingerprint(filter)
+		findOpts := options.Find()
+		if values.sort != nil {
+			findOpts.SetSort(values.sort)
+		}
+		if values.projection != nil {
+			findOpts.SetProjection(values.projection)
+		}
+		if op.Limit != 0 {
+			findOpts.SetLimit(op.Limit)
+		}
+		if op.Skip != 0 {
+			findOpts.SetSkip(op.Skip)
+		}
+		var cursor *mongo.Cursor
+		if cursor, err = collection.Find(ctx, filter, findOpts); err == nil {
+			err = drain(ctx, cursor)
+		}
+	case "aggregate":
+		pipeline := values.pipeline
+		shape = pipelineFingerprint(pipeline)
+		var cursor *mongo.Cursor
+		if cursor, err = collection.Aggregate(ctx, pipeline); err == nil {
+			err = drain(ctx, cursor)
+		}
+	case "update":
+		shape = fingerprint(filter)
+		update := values.update
+		updateOpts := options.Update().SetUpsert(op.Upsert)
+		if op.Multi {
+			_, err = collection.UpdateMany(ctx, filter, update, updateOpts)
+		} else {
+			_, err = collection.UpdateOne(ctx, filter, update, updateOpts)
+		}
+	case "delete":
+		shape = fingerprint(filter)
+		if op.Multi {
+			_, err = collection.DeleteMany(ctx, filter)
+		} else {
+			_, err = collection.DeleteOne(ctx, filter)
+		}
+	case "insert":
+		documents, _ := values.documents.(bson.A)
+		if len(documents) == 0 {
+			if documents, err = r.placeholderDocuments(op.N); err != nil {
+				return err
+			}
+		}
+		if len(documents) > 0 {
+			_, err = collection.InsertMany(ctx, documents)
+		}
+	default:
+		return fmt.Errorf("unsupported workload operation %q", op.Op)
+	}
+	r.reporter.replayOperation(op.Op, collection.Name(), shape, r.shapes, err, time.Since(began))
+	return err
+}
+
+// value decodes a recorded document or array, applying the parameter mapping, or returns def
+// when the value was not recorded.
+func (r *replayer) value(raw bson.RawValue, def interface{}) (interface{}, error) {
+	if raw.Type == 0 {
+		return def, nil
+	}
+	var v interface{}
+	if err := raw.Unmarshal(&v); err != nil {
+		return def, nil
+	}
+	if len(r.mapping) == 0 {
+		return v, nil
+	}
+	return r.substitute(v)
+}
+
+func (r *replayer) substitute(value interface{}) (interface{}, error) {
+	var err error
+	switch v := value.(type) {
+	case bson.D:
+		for i, e := range v {
+			if mapping, ok := r.mapping[e.Key]; ok {
+				v[i].Value, err = r.mapped(e.Key, mapping, e.Value)
+			} else {
+				v[i].Value, err = r.substitute(e.Value)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	case bson.A:
+		for i, e := range v {
+			if v[i], err = r.substitute(e); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+// mappingError is an exception thrown by a mapping function.
+type mappingError struct {
+	field string
+	err   error
+}
+
+func (e *mappingError) Error() string {
+	return fmt.Sprintf("mapping for %s: %v", e.field, e.err)
+}
+
+func (e *mappingError) Unwrap() error {
+	return e.err
+}
+
+func (r *replayer) mapped(field string, mapping goja.Value, original interface{}) (interface{}, error) {
+	fn, ok := goja.AssertFunction(mapping)
+	if !ok {
+		return mapping.Export(), nil
+	}
+	rt := r.vu.Runtime()
+	result, err := fn(goja.Undefined(), rt.ToValue(original))
+	if err != nil {
+		return nil, &mappingError{field, err}
+	}
+	return result.Export(), nil
+}
+
+func (r *replayer) placeholderDocuments(n int) (bson.A, error) {
+	if n <= 0 || len(r.mapping) == 0 {
+		return nil, nil
+	}
+	documents := make(bson.A, 0, n)
+	for i := 0; i < n; i++ {
+		doc := make(bson.D, 0, len(r.mapping))
+		for key, mapping := range r.mapping {
+			value, err := r.mapped(key, mapping, nil)
+			if err != nil {
+				return nil, err
+			}
+			doc = append(doc, bson.E{Key: key, Value: value})
+		}
+		documents = append(documents, doc)
+	}
+	return documents, nil
+}
+
+func drain(ctx context.Context, cursor *mongo.Cursor) error {
+	defer cursor.Close(ctx)
+	for cursor.Next(ctx) {
+	}
+	return cursor.Err()
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	wait := time.Until(t)
+	if wait <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// collectionName returns the collection part of a "database.collection" namespace.
+func collectionName(namespace string) string {
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
@@ -0,0 +1,201 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultRetryInitialBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff     = 5 * time.Second
+	defaultRetryMultiplier     = 2
+	defaultRetryJitter         = 0.5
+)
+
+// errorCodes maps the server error code names accepted in retry policies to their codes.
+var errorCodes = map[string]int{
+	"HostUnreachable":                 6,
+	"HostNotFound":                    7,
+	"NetworkTimeout":                  89,
+	"ShutdownInProgress":              91,
+	"WriteConflict":                   112,
+	"PrimarySteppedDown":              189,
+	"ExceededTimeLimit":               262,
+	"SocketException":                 9001,
+	"NotWritablePrimary":              10107,
+	"InterruptedAtShutdown":           11600,
+	"InterruptedDueToReplStateChange": 11602,
+	"NotPrimaryNoSecondaryOk":         13435,
+	"NotPrimaryOrSecondary":           13436,
+}
+
+// defaultRetryErrors are retried when a policy does not list its own.
+var defaultRetryErrors = []string{
+	"RetryableWriteError",
+	"TransientTransactionError",
+	"NotWritablePrimary",
+	"NotPrimaryNoSecondaryOk",
+	"NotPrimaryOrSecondary",
+	"PrimarySteppedDown",
+	"InterruptedDueToReplStateChange",
+	"ShutdownInProgress",
+	"network",
+}
+
+type RetryOptions struct {
+	// MaxAttempts counts the first attempt, so 1 disables retries.
+	MaxAttempts      int     `js:"maxAttempts"`
+	InitialBackoffMs int64   `js:"initialBackoffMs"`
+	MaxBackoffMs     int64   `js:"maxBackoffMs"`
+	Multiplier       float64 `js:"multiplier"`
+	// Jitter randomizes each backoff by up to this fraction of it, between 0 and 1.
+	Jitter *float64 `js:"jitter"`
+	// Errors lists what to retry: error labels such as "RetryableWriteError", server error code
+	// names such as "WriteConflict", or "network" and "timeout" for client-side failures.
+	Errors []string `js:"errors"`
+	// Codes lists additional server error codes to retry.
+	Codes []int `js:"codes"`
+}
+
+type retryPolicy struct {
+	maxAttempts                int
+	initialBackoff, maxBackoff time.Duration
+	multiplier, jitter         float64
+	labels                     []string
+	codes                      []int
+	network, timeout           bool
+}
+
+func newRetryPolicy(opts RetryOptions) (*retryPolicy, error) {
+	p := &retryPolicy{
+		maxAttempts:    opts.MaxAttempts,
+		initialBackoff: time.Duration(opts.InitialBackoffMs) * time.Millisecond,
+		maxBackoff:     time.Duration(opts.MaxBackoffMs) * time.Millisecond,
+		multiplier:     opts.Multiplier,
+		jitter:         defaultRetryJitter,
+		codes:          opts.Codes,
+	}
+	if p.maxAttempts <= 0 {
+		return nil, errors.New("retry maxAttempts must be at least 1")
+	}
+	if p.initialBackoff <= 0 {
+		p.initialBackoff = defaultRetryInitialBackoff
+	}
+	if p.maxBackoff <= 0 {
+		p.maxBackoff = defaultRetryMaxBackoff
+	}
+	if p.multiplier < 1 {
+		p.multiplier = defaultRetryMultiplier
+	}
+	if opts.Jitter != nil {
+		if *opts.Jitter < 0 || *opts.Jitter > 1 {
+			return nil, fmt.Errorf("retry jitter must be between 0 and 1, not %v", *opts.Jitter)
+		}
+		p.jitter = *opts.Jitter
+	}
+	names := opts.Errors
+	if len(names) == 0 && len(opts.Codes) == 0 {
+		names = defaultRetryErrors
+	}
+	for _, name := range names {
+		switch name {
+		case "network":
+			p.network = true
+		case "timeout":
+			p.timeout = true
+		default:
+			if code, ok := errorCodes[name]; ok {
+				p.codes = append(p.codes, code)
+			} else {
+				p.labels = append(p.labels, name)
+			}
+		}
+	}
+	return p, nil
+}
+
+// retryable reports whether err matches one of the policy's errors.
+func (p *retryPolicy) retryable(err error) bool {
+	if p.network && mongo.IsNetworkError(err) {
+		return true
+	}
+	if p.timeout && mongo.IsTimeout(err) {
+		return true
+	}
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+	for _, label := range p.labels {
+		if serverErr.HasErrorLabel(label) {
+			return true
+		}
+	}
+	for _, code := range p.codes {
+		if serverErr.HasErrorCode(code) {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait before the given retry, counting from 1.
+func (p *retryPolicy) backoff(retry int) time.Duration {
+	d := float64(p.initialBackoff) * math.Pow(p.multiplier, float64(retry-1))
+	if d > float64(p.maxBackoff) {
+		d = float64(p.maxBackoff)
+	}
+	d -= d * p.jitter * rand.Float64()
+	return time.Duration(d)
+}
+
+// SetRetry sets the retry policy of this handle's operations. Calls can override it with their
+// retry parameter.
+func (m *mongodb) SetRetry(opts RetryOptions) error {
+	policy, err := newRetryPolicy(opts)
+	if err != nil {
+		return err
+	}
+	m.retry = policy
+	return nil
+}
+
+// run performs an operation, retrying it according to the call's or the handle's retry policy.
+// Every attempt reports mongo_op_duration; retries are counted in mongo_retries and the time
+// from the first failure to the final outcome goes to mongo_retry_duration.
+func (m *mongodb) run(op string, query interface{}, p *callParams, attempt func() error) error {
+	policy := m.retry
+	if p != nil && p.retry != nil {
+		policy = p.retry
+	}
+	ctx := context.Background()
+	if m.Reporter != nil && m.Reporter.vu != nil {
+		ctx = m.Reporter.vu.Context()
+	}
+	var firstFailure time.Time
+	for n := 1; ; n++ {
+		began := time.Now()
+		m.Reporter.startSelection()
+		err := attempt()
+		m.observe(op, query, p, began, err)
+		if err == nil || policy == nil || n >= policy.maxAttempts || !policy.retryable(err) {
+			if n > 1 {
+				m.Reporter.retried(op, m.Collection.Name(), err == nil, time.Since(firstFailure))
+			}
+			return err
+		}
+		if n == 1 {
+			firstFailure = time.Now()
+		}
+		m.Reporter.retry(op, m.Collection.Name())
+		if sleepUntil(ctx, time.Now().Add(policy.backoff(n))) != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.k6.io/k6/event"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const defaultSamplerInterval = 5 * time.Second
+
+var (
+	sampledOpcounters = []string{"insert", "query", "update", "delete", "getmore", "command"}
+	sampledQueues     = []string{"total", "readers", "writers"}
+)
+
+type ServerStatusSamplerOptions struct {
+	// Interval between samples in milliseconds.
+	Interval int64 `js:"interval"`
+}
+
+type serverStatusSampler struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartServerStatusSampler periodically runs serverStatus, and replSetGetStatus when connected to
+// a replica set, and reports the results as gauges tagged by host. Samples carry the tags in
+// effect when the sampler was started. The VU's context only lasts for the current iteration,
+// so the sampler is tied to the test instead: it keeps running until Stop is called or the test
+// ends.
+//
+// serverStatus runs on the server the client selects for the primary read preference, so only
+// that server's gauges are reported. Other members are sampled by starting a sampler on a
+// database opened with directConnection=true to each of them.
+func (d *database) StartServerStatusSampler(opts ServerStatusSamplerOptions) (*serverStatusSampler, error) {
+	sink := d.Reporter.sink()
+	if sink == nil {
+		return nil, errors.New("the server status sampler can only be started from VU code, not the init context")
+	}
+	interval := defaultSamplerInterval
+	if opts.Interval > 0 {
+		interval = time.Duration(opts.Interval) * time.Millisecond
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sink.ctx = ctx
+	s := &serverStatusSampler{
+		cancel,
+		make(chan struct{}),
+	}
+	var (
+		global  event.Subscriber
+		subID   uint64
+		testEnd <-chan *event.Event
+	)
+	if d.vu != nil {
+		if global = d.vu.Events().Global; global != nil {
+			subID, testEnd = global.Subscribe(event.TestEnd)
+		}
+	}
+	go func() {
+		defer close(s.done)
+		defer cancel()
+		if global != nil {
+			defer global.Unsubscribe(subID)
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := d.sampleServerStatus(ctx, sink); err != nil && ctx.Err() == nil {
+				sink.logger.WithError(err).Warn("mongo: sampling server status failed")
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case e := <-testEnd:
+				if e != nil {
+					e.Done()
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return s, nil
+}
+
+func (s *serverStatusSampler) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+func (d *database) sampleServerStatus(ctx context.Context, sink *sampleSink) error {
+	m := d.Reporter.metrics
+	admin := d.MongoClient.Database("admin")
+	var status bson.M
+	err := admin.RunCommand(ctx, bson.D{{Key: "serverStatus", Value: 1}}).Decode(&status)
+	if err != nil {
+		return err
+	}
+	host, _ := status["host"].(string)
+	tags := map[string]string{"host": host}
+
+	if v, ok := lookupNumber(status, "connections", "current"); ok {
+		sink.push(m.ServerConnections, v, tags)
+	}
+	for _, op := range sampledOpcounters {
+		if v, ok := lookupNumber(status, "opcounters", op); ok {
+			sink.push(m.ServerOpcounters, v, withTag(tags, "op", op))
+		}
+	}
+	used, hasUsed := lookupNumber(status, "wiredTiger", "cache", "bytes currently in the cache")
+	if hasUsed {
+		sink.push(m.ServerCacheBytes, used, tags)
+	}
+	if capacity, ok := lookupNumber(status, "wiredTiger", "cache", "maximum bytes configured"); ok && hasUsed && capacity > 0 {
+		sink.push(m.ServerCacheUsage, used/capacity, tags)
+	}
+	for _, queue := range sampledQueues {
+		if v, ok := lookupNumber(status, "globalLock", "currentQueue", queue); ok {
+			sink.push(m.ServerQueueLength, v, withTag(tags, "queue", queue))
+		}
+	}
+
+	// replSetGetStatus fails on standalone servers, which simply have no lag to report.
+	var replStatus bson.M
+	if admin.RunCommand(ctx, bson.D{{Key: "replSetGetStatus", Value: 1}}).Decode(&replStatus) != nil {
+		return nil
+	}
+	members, _ := replStatus["members"].(bson.A)
+	var primaryOptime primitive.DateTime
+	for _, member := range members {
+		if doc, ok := member.(bson.M); ok && doc["stateStr"] == "PRIMARY" {
+			primaryOptime, _ = doc["optimeDate"].(primitive.DateTime)
+		}
+	}
+	if primaryOptime == 0 {
+		return nil
+	}
+	for _, member := range members {
+		doc, ok := member.(bson.M)
+		if !ok || doc["stateStr"] != "SECONDARY" {
+			continue
+		}
+		optime, _ := doc["optimeDate"].(primitive.DateTime)
+		name, _ := doc["name"].(string)
+		lag := primaryOptime.Time().Sub(optime.Time())
+		sink.push(m.ServerReplicationLag, durationMs(lag), map[string]string{"host": name})
+	}
+	return nil
+}
+
+// lookupNumber follows path through nested documents and returns the number found there,
+// whichever BSON numeric type the server used for it.
+func lookupNumber(doc bson.M, path ...string) (float64, bool) {
+	var value interface{} = doc
+	for _, key := range path {
+		m, ok := value.(bson.M)
+		if !ok {
+			return 0, false
+		}
+		value = m[key]
+	}
+	switch n := value.(type) {
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+func withTag(tags map[string]string, key, value string) map[string]string {
+	merged := make(map[string]string, len(tags)+1)
+	for k, v := range tags {
+		merged[k] = v
+	}
+	merged[key] = value
+	return merged
+}
@@ -0,0 +1,285 @@
+package mongo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultSeedBatchSize = 1000
+	defaultSeedWorkers   = 4
+	seedProgressInterval = 5 * time.Second
+	// maxSeedLineSize bounds a single NDJSON line, which cannot exceed the 16MB BSON limit by much.
+	maxSeedLineSize = 32 * 1024 * 1024
+)
+
+type SeedOptions struct {
+	BatchSize int `js:"batchSize"`
+	Workers   int `js:"workers"`
+	// Ordered stops each batch at its first failing document. Batches themselves are inserted in
+	// parallel, so there is no ordering across batches.
+	Ordered bool `js:"ordered"`
+}
+
+// documentSource yields the documents to seed, returning io.EOF after the last one. Sources
+// backed by JS values may only be read from the VU goroutine.
+type documentSource interface {
+	next() (interface{}, error)
+}
+
+// Seed bulk loads documents into the collection with parallel InsertMany batches and logs its
+// progress. The source can be:
+//   - a generator description, {template, count, seed, targetSize}, as accepted by generate
+//   - the contents of a JSON array, NDJSON or Extended JSON file read with open()
+//   - an array of documents, including a SharedArray
+//
+// It returns the number of documents inserted.
+func (m *mongodb) Seed(source goja.Value, opts SeedOptions) (int64, error) {
+	src, err := newDocumentSource(source)
+	if err != nil {
+		return 0, err
+	}
+	return m.insertFrom(src, opts)
+}
+
+// insertFrom reads src on the calling goroutine and inserts its documents from opts.Workers
+// goroutines.
+func (m *mongodb) insertFrom(src documentSource, opts SeedOptions) (int64, error) {
+	defer m.Reporter.flushConnections()
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultSeedBatchSize
+	}
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultSeedWorkers
+	}
+	var logger logrus.FieldLogger
+	if sink := m.Reporter.sink(); sink != nil {
+		logger = sink.logger.WithField("collection", m.Collection.Name())
+	}
+
+	ctx, cancel := context.WithCancel(context.TODO())
+	defer cancel()
+	insertOpts := options.InsertMany().SetOrdered(opts.Ordered)
+	batches := make(chan []interface{}, workers)
+	var (
+		inserted  int64
+		insertErr error
+		errOnce   sync.Once
+		wg        sync.WaitGroup
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				result, err := m.Collection.InsertMany(ctx, batch, insertOpts)
+				atomic.AddInt64(&inserted, int64(insertedCount(result, err, opts.Ordered)))
+				if err != nil {
+					errOnce.Do(func() {
+						insertErr = err
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+
+	started := time.Now()
+	lastLog := started
+	var sourceErr error
+	batch := make([]interface{}, 0, batchSize)
+produce:
+	for {
+		doc, err := src.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			sourceErr = err
+			break
+		}
+		batch = append(batch, doc)
+		if len(batch) < batchSize {
+			continue
+		}
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+			break produce
+		}
+		batch = make([]interface{}, 0, batchSize)
+		if logger != nil && time.Since(lastLog) >= seedProgressInterval {
+			logSeedProgress(logger, "mongo: seeding in progress", atomic.LoadInt64(&inserted), started)
+			lastLog = time.Now()
+		}
+	}
+	if len(batch) > 0 && sourceErr == nil {
+		select {
+		case batches <- batch:
+		case <-ctx.Done():
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if logger != nil {
+		logSeedProgress(logger, "mongo: seeding finished", inserted, started)
+	}
+	if sourceErr != nil {
+		return inserted, sourceErr
+	}
+	return inserted, insertErr
+}
+
+func logSeedProgress(logger logrus.FieldLogger, msg string, inserted int64, started time.Time) {
+	elapsed := time.Since(started)
+	logger.WithFields(logrus.Fields{
+		"documents":  inserted,
+		"elapsed":    elapsed.Round(time.Millisecond).String(),
+		"throughput": strconv.FormatFloat(float64(inserted)/elapsed.Seconds(), 'f', 0, 64) + " docs/s",
+	}).Info(msg)
+}
+
+func newDocumentSource(source goja.Value) (documentSource, error) {
+	if source == nil || goja.IsUndefined(source) || goja.IsNull(source) {
+		return nil, errors.New("a seed source is required")
+	}
+	obj, ok := source.(*goja.Object)
+	if !ok {
+		return newFileSource([]byte(source.String())), nil
+	}
+	if obj.ClassName() == "ArrayBuffer" {
+		if ab, ok := obj.Export().(goja.ArrayBuffer); ok {
+			return newFileSource(ab.Bytes()), nil
+		}
+	}
+	if template := obj.Get("template"); template != nil && !goja.IsUndefined(template) {
+		tpl, _ := template.Export().(map[string]interface{})
+		gen, err := newDocumentGenerator(tpl, GenerateOptions{
+			Seed:       intProperty(obj, "seed"),
+			TargetSize: int(intProperty(obj, "targetSize")),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &generatorSource{gen, int(intProperty(obj, "count"))}, nil
+	}
+	if length := obj.Get("length"); length != nil && !goja.IsUndefined(length) {
+		return &arraySource{obj, int(length.ToInteger()), 0}, nil
+	}
+	return nil, errors.New("unsupported seed source: expected a generator, file contents or an array")
+}
+
+// intProperty returns obj[name] as an integer, or zero when it is not set.
+func intProperty(obj *goja.Object, name string) int64 {
+	v := obj.Get(name)
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return 0
+	}
+	return v.ToInteger()
+}
+
+type generatorSource struct {
+	gen       *documentGenerator
+	remaining int
+}
+
+func (s *generatorSource) next() (interface{}, error) {
+	if s.remaining <= 0 {
+		return nil, io.EOF
+	}
+	s.remaining--
+	return s.gen.next()
+}
+
+// arraySource reads elements of a JS array or SharedArray one at a time, so a SharedArray is
+// never copied as a whole.
+type arraySource struct {
+	array  *goja.Object
+	length int
+	index  int
+}
+
+func (s *arraySource) next() (interface{}, error) {
+	if s.index >= s.length {
+		return nil, io.EOF
+	}
+	doc := toDocument(s.array.Get(strconv.Itoa(s.index)))
+	s.index++
+	return doc, nil
+}
+
+// newFileSource parses a JSON array when data starts with '[' and NDJSON otherwise. Documents
+// may use Extended JSON in either canonical or relaxed form.
+func newFileSource(data []byte) documentSource {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return &jsonArraySource{decoder: json.NewDecoder(bytes.NewReader(trimmed))}
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxSeedLineSize)
+	return &ndjsonSource{scanner}
+}
+
+type jsonArraySource struct {
+	decoder *json.Decoder
+	started bool
+}
+
+func (s *jsonArraySource) next() (interface{}, error) {
+	if !s.started {
+		if _, err := s.decoder.Token(); err != nil {
+			return nil, err
+		}
+		s.started = true
+	}
+	if !s.decoder.More() {
+		return nil, io.EOF
+	}
+	var raw json.RawMessage
+	if err := s.decoder.Decode(&raw); err != nil {
+		return nil, err
+	}
+	return parseExtJSON(raw)
+}
+
+type ndjsonSource struct {
+	scanner *bufio.Scanner
+}
+
+func (s *ndjsonSource) next() (interface{}, error) {
+	for s.scanner.Scan() {
+		line := bytes.TrimSpace(s.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		return parseExtJSON(line)
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func parseExtJSON(data []byte) (bson.D, error) {
+	var doc bson.D
+	if err := bson.UnmarshalExtJSON(data, false, &doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
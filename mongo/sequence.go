@@ -0,0 +1,116 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultSequenceBlockSize = 100
+
+type SequenceOptions struct {
+	// Start is the first value handed out.
+	Start int64 `js:"start"`
+	// BlockSize is the number of values a VU reserves at a time. Values are unique across VUs,
+	// but only increase within a VU.
+	BlockSize int64 `js:"blockSize"`
+	// Counters is a collection handle. When set, blocks are reserved with findOneAndUpdate $inc
+	// on the document named after the sequence, so values stay unique across k6 instances and
+	// runs.
+	Counters interface{} `js:"counters"`
+}
+
+// sharedSequences holds the next unreserved value of every in-memory sequence.
+var sharedSequences = struct {
+	sync.Mutex
+	byName map[string]*int64
+}{byName: map[string]*int64{}}
+
+// sequence hands out the values of a block reserved from a shared counter.
+type sequence struct {
+	name      string
+	start     int64
+	blockSize int64
+	counters  *mongodb
+	next, end int64
+}
+
+// NewSequence creates a VU's view of the named sequence.
+func NewSequence(name string, opts SequenceOptions) (*sequence, error) {
+	if name == "" {
+		return nil, errors.New("sequence name is required")
+	}
+	s := &sequence{name: name, start: opts.Start, blockSize: opts.BlockSize}
+	if s.blockSize <= 0 {
+		s.blockSize = defaultSequenceBlockSize
+	}
+	if opts.Counters != nil {
+		counters, ok := opts.Counters.(*mongodb)
+		if !ok {
+			return nil, errors.New("sequence counters must be a collection handle")
+		}
+		s.counters = counters
+	}
+	return s, nil
+}
+
+// Next returns the next value of the sequence, reserving a new block when the current one is
+// used up.
+func (s *sequence) Next() (int64, error) {
+	if s.next == s.end {
+		first, err := s.reserve()
+		if err != nil {
+			return 0, err
+		}
+		s.next, s.end = first, first+s.blockSize
+	}
+	value := s.next
+	s.next++
+	return value, nil
+}
+
+// NextObjectID returns the next value of the sequence as an ObjectId, for collections that use
+// ObjectId keys.
+func (s *sequence) NextObjectID() (primitive.ObjectID, error) {
+	value, err := s.Next()
+	if err != nil {
+		return primitive.NilObjectID, err
+	}
+	return indexObjectID(value), nil
+}
+
+// reserve returns the first value of a new block.
+func (s *sequence) reserve() (int64, error) {
+	if s.counters == nil {
+		sharedSequences.Lock()
+		counter, ok := sharedSequences.byName[s.name]
+		if !ok {
+			counter = new(int64)
+			*counter = s.start
+			sharedSequences.byName[s.name] = counter
+		}
+		sharedSequences.Unlock()
+		return atomic.AddInt64(counter, s.blockSize) - s.blockSize, nil
+	}
+
+	s.counters.Reporter.bindDialer()
+	defer s.counters.Reporter.flushConnections()
+	var counter struct {
+		Value int64 `bson:"value"`
+	}
+	err := s.counters.Collection.FindOneAndUpdate(
+		context.TODO(),
+		bson.D{{Key: "_id", Value: s.name}},
+		bson.D{{Key: "$inc", Value: bson.D{{Key: "value", Value: s.blockSize}}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, err
+	}
+	return s.start + counter.Value - s.blockSize, nil
+}
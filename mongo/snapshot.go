@@ -0,0 +1,197 @@
+package mongo
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Snapshot describes copies of collections taken by database.Snapshot. It only holds plain data
+// so that it survives being returned from setup() and handed to teardown().
+type Snapshot struct {
+	ID          string               `js:"id"`
+	Archive     string               `js:"archive"`
+	Collections []SnapshotCollection `js:"collections"`
+}
+
+type SnapshotCollection struct {
+	Name   string `js:"name"`
+	Shadow string `js:"shadow"`
+	// Indexes holds the collection's index specifications as canonical Extended JSON, which
+	// keeps the key order of compound indexes intact through JSON round trips.
+	Indexes []string `js:"indexes"`
+}
+
+type SnapshotOptions struct {
+	// Archive is a directory to write the snapshot to as mongodump-style BSON files instead of
+	// shadow collections in the same database.
+	Archive string `js:"archive"`
+}
+
+// Snapshot copies collections, with their indexes, into shadow collections or a local archive
+// so that Restore can bring them back. Collection options such as validators are not copied.
+func (d *database) Snapshot(collections []string, opts SnapshotOptions) (*Snapshot, error) {
+	ctx := context.TODO()
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	snapshot := &Snapshot{
+		ID:      primitive.NewObjectID().Hex(),
+		Archive: opts.Archive,
+	}
+	if opts.Archive != "" {
+		if err := os.MkdirAll(opts.Archive, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range collections {
+		indexes, err := d.indexSpecifications(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		entry := SnapshotCollection{Name: name, Indexes: indexes}
+		if opts.Archive != "" {
+			_, err = d.Collection(name).ExportTo(archivePath(opts.Archive, name), ExportOptions{Format: formatBSON})
+		} else {
+			entry.Shadow = name + "_snapshot_" + snapshot.ID
+			err = d.copyCollection(ctx, name, entry.Shadow)
+		}
+		if err != nil {
+			return nil, err
+		}
+		snapshot.Collections = append(snapshot.Collections, entry)
+	}
+	return snapshot, nil
+}
+
+// Restore replaces every collection in snapshot with its snapshotted data and indexes, then
+// drops the shadow collections. Each collection is rebuilt under a temporary name and renamed
+// over the original, so a missing shadow collection or a failed import leaves it untouched.
+func (d *database) Restore(snapshot Snapshot) error {
+	ctx := context.TODO()
+	d.Reporter.bindDialer()
+	defer d.Reporter.flushConnections()
+	for _, entry := range snapshot.Collections {
+		if err := d.restoreCollection(ctx, snapshot, entry); err != nil {
+			return fmt.Errorf("restoring %s: %w", entry.Name, err)
+		}
+		if entry.Shadow != "" {
+			if err := d.Database.Collection(entry.Shadow).Drop(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *database) restoreCollection(ctx context.Context, snapshot Snapshot, entry SnapshotCollection) (err error) {
+	if entry.Shadow != "" {
+		names, err := d.Database.ListCollectionNames(ctx, bson.D{{Key: "name", Value: entry.Shadow}})
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			return fmt.Errorf("shadow collection %s does not exist", entry.Shadow)
+		}
+	}
+	temporary := d.Database.Collection(entry.Name + "_restore_" + snapshot.ID)
+	defer func() {
+		if err != nil {
+			temporary.Drop(ctx)
+		}
+	}()
+	if err = temporary.Drop(ctx); err != nil {
+		return err
+	}
+	// The collection is created up front so that an empty snapshot still has something to rename.
+	if err = d.Database.CreateCollection(ctx, temporary.Name()); err != nil {
+		return err
+	}
+	if snapshot.Archive != "" {
+		_, err = d.Collection(temporary.Name()).ImportFrom(archivePath(snapshot.Archive, entry.Name), ImportOptions{Format: formatBSON})
+	} else {
+		err = d.copyCollection(ctx, entry.Shadow, temporary.Name())
+	}
+	if err != nil {
+		return err
+	}
+	if err = d.createIndexes(ctx, temporary.Name(), entry.Indexes); err != nil {
+		return err
+	}
+	rename := bson.D{
+		{Key: "renameCollection", Value: d.Database.Name() + "." + temporary.Name()},
+		{Key: "to", Value: d.Database.Name() + "." + entry.Name},
+		{Key: "dropTarget", Value: true},
+	}
+	return d.Database.Client().Database("admin").RunCommand(ctx, rename).Err()
+}
+
+func (d *database) copyCollection(ctx context.Context, from, to string) error {
+	pipeline := bson.A{bson.D{{Key: "$out", Value: to}}}
+	cursor, err := d.Database.Collection(from).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	return cursor.Close(ctx)
+}
+
+// indexSpecifications returns the specifications of every index but the implicit _id index.
+func (d *database) indexSpecifications(ctx context.Context, collection string) ([]string, error) {
+	cursor, err := d.Database.Collection(collection).Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+	specs := []string{}
+	for cursor.Next(ctx) {
+		if name, _ := cursor.Current.Lookup("name").StringValueOK(); name == "_id_" {
+			continue
+		}
+		spec, err := bson.MarshalExtJSON(cursor.Current, true, false)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, string(spec))
+	}
+	return specs, cursor.Err()
+}
+
+func (d *database) createIndexes(ctx context.Context, collection string, specs []string) error {
+	if len(specs) == 0 {
+		return nil
+	}
+	indexes := make(bson.A, 0, len(specs))
+	for _, spec := range specs {
+		var index bson.D
+		if err := bson.UnmarshalExtJSON([]byte(spec), true, &index); err != nil {
+			return err
+		}
+		indexes = append(indexes, withoutKeys(index, "v", "ns"))
+	}
+	command := bson.D{
+		{Key: "createIndexes", Value: collection},
+		{Key: "indexes", Value: indexes},
+	}
+	return d.Database.RunCommand(ctx, command).Err()
+}
+
+func withoutKeys(doc bson.D, keys ...string) bson.D {
+	filtered := make(bson.D, 0, len(doc))
+next:
+	for _, e := range doc {
+		for _, key := range keys {
+			if e.Key == key {
+				continue next
+			}
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+func archivePath(dir, collection string) string {
+	return filepath.Join(dir, collection+".bson")
+}
@@ -0,0 +1,76 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const defaultMeasurementBatchSize = 1000
+
+// InsertMeasurements ingests measurements into a time-series collection in unordered InsertMany
+// batches of batchSize, which lets the server bucket each batch in one pass. Every batch reports
+// the number of measurements written and its latency. It returns the number of measurements
+// inserted before the first failing batch.
+func (m *mongodb) InsertMeasurements(measurements []interface{}, batchSize int) (int, error) {
+	ctx := context.TODO()
+	m.Reporter.bindDialer()
+	defer m.Reporter.flushConnections()
+	if batchSize <= 0 {
+		batchSize = defaultMeasurementBatchSize
+	}
+	inserted := 0
+	opts := options.InsertMany().SetOrdered(false)
+	for start := 0; start < len(measurements); start += batchSize {
+		end := start + batchSize
+		if end > len(measurements) {
+			end = len(measurements)
+		}
+		began := time.Now()
+		result, err := m.Collection.InsertMany(ctx, measurements[start:end], opts)
+		if result != nil {
+			written := insertedCount(result, err, false)
+			inserted += written
+			m.Reporter.timeSeriesBatch(m.Collection.Name(), written, time.Since(began))
+		}
+		if err != nil {
+			return inserted, err
+		}
+	}
+	return inserted, nil
+}
+
+// insertedCount returns how many documents of an InsertMany batch were written. The driver
+// reports the IDs of the whole batch even when some documents were rejected: an unordered batch
+// writes every document without a write error and an ordered one stops at its first error.
+// Nothing is counted for errors that leave the outcome unknown, such as network errors.
+func insertedCount(result *mongo.InsertManyResult, err error, ordered bool) int {
+	if result == nil {
+		return 0
+	}
+	n := len(result.InsertedIDs)
+	if err == nil {
+		return n
+	}
+	var bwe mongo.BulkWriteException
+	if !errors.As(err, &bwe) {
+		return 0
+	}
+	if len(bwe.WriteErrors) == 0 {
+		// Only the write concern failed, the documents were written.
+		return n
+	}
+	if !ordered {
+		return n - len(bwe.WriteErrors)
+	}
+	first := n
+	for _, we := range bwe.WriteErrors {
+		if we.Index < first {
+			first = we.Index
+		}
+	}
+	return first
+}
@@ -0,0 +1,275 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"go.k6.io/k6/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/description"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+const (
+	defaultReadyTimeout = 30 * time.Second
+	// readyRetryInterval separates the pings of waitUntilReady once one has failed.
+	readyRetryInterval = 500 * time.Millisecond
+)
+
+type WarmupOptions struct {
+	// Connections is the number of established connections to wait for on every selectable
+	// server. It defaults to the client's minPoolSize and cannot exceed it, since the driver
+	// only keeps minPoolSize connections open.
+	Connections uint64 `js:"connections"`
+	// Timeout is in milliseconds.
+	Timeout int64 `js:"timeout"`
+}
+
+type ReadyOptions struct {
+	// ReadPreference is a mode string or a ReadPreferenceOptions object, primary by default.
+	ReadPreference goja.Value `js:"readPreference"`
+	// Timeout is in milliseconds.
+	Timeout int64 `js:"timeout"`
+}
+
+// pools follows the client's data-bearing servers and the connections established to each of
+// them, from the driver's topology and pool events.
+type pools struct {
+	minPoolSize uint64
+
+	mu      sync.Mutex
+	servers []string
+	ready   map[string]map[uint64]struct{}
+	// changed is closed and replaced whenever the servers or their connections change.
+	changed chan struct{}
+}
+
+func newPools() *pools {
+	return &pools{
+		ready:   make(map[string]map[uint64]struct{}),
+		changed: make(chan struct{}),
+	}
+}
+
+func (p *pools) topologyChanged(topology description.Topology) {
+	if p == nil {
+		return
+	}
+	servers := make([]string, 0, len(topology.Servers))
+	for _, s := range topology.Servers {
+		if s.DataBearing() || s.Kind == description.LoadBalancer {
+			servers = append(servers, s.Addr.String())
+		}
+	}
+	sort.Strings(servers)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.servers = servers
+	p.notify()
+}
+
+func (p *pools) connectionReady(address string, id uint64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.ready[address] == nil {
+		p.ready[address] = make(map[uint64]struct{})
+	}
+	p.ready[address][id] = struct{}{}
+	p.notify()
+}
+
+func (p *pools) connectionClosed(address string, id uint64) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.ready[address], id)
+	p.notify()
+}
+
+// notify wakes up the waiters of changed. p.mu must be held.
+func (p *pools) notify() {
+	close(p.changed)
+	p.changed = make(chan struct{})
+}
+
+// state returns the established connections per selectable server and a channel closed on the
+// next change.
+func (p *pools) state() (map[string]int, <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	connections := make(map[string]int, len(p.servers))
+	for _, s := range p.servers {
+		connections[s] = len(p.ready[s])
+	}
+	return connections, p.changed
+}
+
+// target returns the number of connections per server a warmup of connections waits for.
+func (p *pools) target(connections uint64) (uint64, error) {
+	if connections == 0 {
+		connections = p.minPoolSize
+	}
+	if connections == 0 {
+		return 0, errors.New("warmup requires minPoolSize to be set on the client")
+	}
+	if connections > p.minPoolSize {
+		return 0, fmt.Errorf("warmup of %d connections exceeds the client's minPoolSize of %d", connections, p.minPoolSize)
+	}
+	return connections, nil
+}
+
+// warmup waits until every selectable server of the client has opts.Connections established
+// connections and returns the connections per server. The driver opens minPoolSize connections
+// to each server in the background once its first heartbeat succeeds; warmup lets the dialer
+// open them and blocks until they are there.
+func warmup(reporter *Reporter, opts WarmupOptions) (map[string]int, error) {
+	if reporter == nil {
+		return nil, errors.New("warmup requires a client created by the module")
+	}
+	sink := reporter.sink()
+	if sink == nil {
+		return nil, errors.New("warmup can only run from VU code, not the init context")
+	}
+	defer reporter.flushConnections()
+	target, err := reporter.pools.target(opts.Connections)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(sink.ctx, readyTimeout(opts.Timeout))
+	defer cancel()
+	for {
+		connections, changed := reporter.pools.state()
+		if warm(connections, target) {
+			return connections, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return connections, fmt.Errorf("warmup to %d connections per server: %w (%s)", target, ctx.Err(), describePools(connections))
+		}
+	}
+}
+
+func warm(connections map[string]int, target uint64) bool {
+	if len(connections) == 0 {
+		return false
+	}
+	for _, n := range connections {
+		if uint64(n) < target {
+			return false
+		}
+	}
+	return true
+}
+
+// describePools lists the connections per server, such as "a:27017=10, b:27017=3".
+func describePools(connections map[string]int) string {
+	if len(connections) == 0 {
+		return "no selectable servers"
+	}
+	servers := make([]string, 0, len(connections))
+	for s, n := range connections {
+		servers = append(servers, fmt.Sprintf("%s=%d", s, n))
+	}
+	sort.Strings(servers)
+	return strings.Join(servers, ", ")
+}
+
+// waitUntilReady pings the cluster with the read preference until server selection succeeds or
+// the timeout expires. The driver's own server selection timeout only bounds each attempt.
+func waitUntilReady(client *mongo.Client, reporter *Reporter, opts ReadyOptions) error {
+	if reporter == nil || reporter.vu == nil {
+		return errors.New("waitUntilReady requires a client created by the module")
+	}
+	sink := reporter.sink()
+	if sink == nil {
+		return errors.New("waitUntilReady can only run from VU code, such as setup, not the init context")
+	}
+	defer reporter.flushConnections()
+	rp := readpref.Primary()
+	if opts.ReadPreference != nil && !goja.IsUndefined(opts.ReadPreference) && !goja.IsNull(opts.ReadPreference) {
+		var err error
+		if rp, _, err = parseReadPreference(reporter.vu.Runtime(), opts.ReadPreference); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(sink.ctx, readyTimeout(opts.Timeout))
+	defer cancel()
+	for {
+		err := client.Ping(ctx, rp)
+		if err == nil {
+			return nil
+		}
+		if sleepUntil(ctx, time.Now().Add(readyRetryInterval)) != nil {
+			return fmt.Errorf("cluster not ready: %w", err)
+		}
+	}
+}
+
+// warmupOnFirstIteration warms the client's pools before the VU's first iteration starts. Each VU
+// has its own client, and its dialer only opens connections once the VU runs code, so neither
+// setup nor the init context can warm the pools the iterations use. k6 waits for the IterStart
+// event to be handled before calling the iteration, so the wait is not part of it.
+func (r *Reporter) warmupOnFirstIteration(opts WarmupOptions) {
+	if r == nil || r.vu == nil {
+		return
+	}
+	local := r.vu.Events().Local
+	if local == nil {
+		return
+	}
+	subID, events := local.Subscribe(event.IterStart)
+	go func() {
+		e, ok := <-events
+		if !ok {
+			return
+		}
+		local.Unsubscribe(subID)
+		defer e.Done()
+		if _, err := warmup(r, opts); err != nil {
+			if sink := r.sink(); sink != nil {
+				sink.logger.WithError(err).Warn("mongo: warming up the connection pool failed")
+			}
+		}
+	}()
+}
+
+func readyTimeout(ms int64) time.Duration {
+	if ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return defaultReadyTimeout
+}
+
+// Warmup waits until the client holds opts.Connections established connections to every
+// selectable server. The warmup client option does the same before the VU's first iteration.
+func (m *mongodb) Warmup(opts WarmupOptions) (map[string]int, error) {
+	return warmup(m.Reporter, opts)
+}
+
+// WaitUntilReady blocks until a server matching the read preference can be selected.
+func (m *mongodb) WaitUntilReady(opts ReadyOptions) error {
+	return waitUntilReady(m.MongoClient, m.Reporter, opts)
+}
+
+// Warmup waits until the client holds opts.Connections established connections to every
+// selectable server. The warmup client option does the same before the VU's first iteration.
+func (d *database) Warmup(opts WarmupOptions) (map[string]int, error) {
+	return warmup(d.Reporter, opts)
+}
+
+// WaitUntilReady blocks until a server matching the read preference can be selected.
+func (d *database) WaitUntilReady(opts ReadyOptions) error {
+	return waitUntilReady(d.MongoClient, d.Reporter, opts)
+}
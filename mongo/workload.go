@@ -0,0 +1,237 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	workloadRead            = "read"
+	workloadUpdate          = "update"
+	workloadInsert          = "insert"
+	workloadScan            = "scan"
+	workloadReadModifyWrite = "readModifyWrite"
+
+	defaultWorkloadRecordCount = 1000
+	defaultWorkloadFieldCount  = 10
+	defaultWorkloadFieldLength = 100
+	defaultWorkloadMaxScan     = 100
+)
+
+type WorkloadOptions struct {
+	// Preset is one of the YCSB core workloads "a" to "f". Explicit proportions replace the
+	// preset's mix and an explicit key distribution replaces its distribution.
+	Preset          string  `js:"preset"`
+	Read            float64 `js:"read"`
+	Update          float64 `js:"update"`
+	Insert          float64 `js:"insert"`
+	Scan            float64 `js:"scan"`
+	ReadModifyWrite float64 `js:"readModifyWrite"`
+	// KeyDistribution is "uniform", "zipfian", "scrambledZipfian", "hotspot", "latest" or
+	// "sequential".
+	KeyDistribution string `js:"keyDistribution"`
+	// RecordCount is the number of records loaded by load() and the initial size of the keyspace.
+	RecordCount   int64 `js:"recordCount"`
+	FieldCount    int   `js:"fieldCount"`
+	FieldLength   int   `js:"fieldLength"`
+	MaxScanLength int   `js:"maxScanLength"`
+	Seed          int64 `js:"seed"`
+}
+
+// workloadPresets are the YCSB core workloads. YCSB's requestdistribution=zipfian scrambles the
+// popular keys across the keyspace, which scrambledZipfian reproduces.
+var workloadPresets = map[string]WorkloadOptions{
+	"a": {Read: 0.5, Update: 0.5, KeyDistribution: distributionScrambledZipfian},
+	"b": {Read: 0.95, Update: 0.05, KeyDistribution: distributionScrambledZipfian},
+	"c": {Read: 1, KeyDistribution: distributionScrambledZipfian},
+	"d": {Read: 0.95, Insert: 0.05, KeyDistribution: distributionLatest},
+	"e": {Scan: 0.95, Insert: 0.05, KeyDistribution: distributionScrambledZipfian},
+	"f": {Read: 0.5, ReadModifyWrite: 0.5, KeyDistribution: distributionScrambledZipfian},
+}
+
+type workloadStep struct {
+	op        string
+	threshold float64
+}
+
+// workload runs a YCSB-style operation mix against a collection. Records have string keys
+// "user0000000000", "user0000000001", … in _id and fieldCount random string fields.
+type workload struct {
+	m       *mongodb
+	opts    WorkloadOptions
+	steps   []workloadStep
+	total   float64
+	keys    *keyspace
+	chooser keyDistribution
+	rng     *rand.Rand
+}
+
+// Workload prepares a workload for this collection. Its run method executes one operation and
+// is meant to be called once per iteration, while load inserts the initial records from setup.
+// Every operation reports mongo_op_duration tagged with the workload operation as op.
+func (m *mongodb) Workload(opts WorkloadOptions) (*workload, error) {
+	if opts.Preset != "" {
+		preset, ok := workloadPresets[opts.Preset]
+		if !ok {
+			return nil, fmt.Errorf("unknown workload preset %s", opts.Preset)
+		}
+		if opts.Read+opts.Update+opts.Insert+opts.Scan+opts.ReadModifyWrite == 0 {
+			opts.Read, opts.Update, opts.Insert = preset.Read, preset.Update, preset.Insert
+			opts.Scan, opts.ReadModifyWrite = preset.Scan, preset.ReadModifyWrite
+		}
+		if opts.KeyDistribution == "" {
+			opts.KeyDistribution = preset.KeyDistribution
+		}
+	}
+	if opts.RecordCount <= 0 {
+		opts.RecordCount = defaultWorkloadRecordCount
+	}
+	if opts.FieldCount <= 0 {
+		opts.FieldCount = defaultWorkloadFieldCount
+	}
+	if opts.FieldLength <= 0 {
+		opts.FieldLength = defaultWorkloadFieldLength
+	}
+	if opts.MaxScanLength <= 0 {
+		opts.MaxScanLength = defaultWorkloadMaxScan
+	}
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	w := &workload{m: m, opts: opts, rng: rand.New(rand.NewSource(seed))}
+	for _, step := range []struct {
+		op         string
+		proportion float64
+	}{
+		{workloadRead, opts.Read},
+		{workloadUpdate, opts.Update},
+		{workloadInsert, opts.Insert},
+		{workloadScan, opts.Scan},
+		{workloadReadModifyWrite, opts.ReadModifyWrite},
+	} {
+		if step.proportion < 0 {
+			return nil, fmt.Errorf("negative %s proportion", step.op)
+		}
+		if step.proportion > 0 {
+			w.total += step.proportion
+			w.steps = append(w.steps, workloadStep{step.op, w.total})
+		}
+	}
+	if len(w.steps) == 0 {
+		return nil, errors.New("workload has no operations")
+	}
+	namespace := m.Collection.Database().Name() + "." + m.Collection.Name()
+	w.keys = sharedKeyspace(namespace, opts.RecordCount)
+	var err error
+	if w.chooser, err = newKeyDistribution(opts.KeyDistribution, w.keys, 0, 0); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Load inserts the workload's initial records with Seed's parallel batches.
+func (w *workload) Load(opts SeedOptions) (int64, error) {
+	return w.m.insertFrom(&workloadSource{w: w}, opts)
+}
+
+// Run executes one operation picked according to the workload's mix and returns its name. Reads
+// of records that do not exist are misses, not failures.
+func (w *workload) Run() (string, error) {
+	ctx := context.TODO()
+	w.m.Reporter.bindDialer()
+	op := w.pick()
+	key := recordKey(w.chooser.next(w.rng))
+	filter := bson.D{{Key: "_id", Value: key}}
+	var err error
+	began := time.Now()
+	switch op {
+	case workloadRead:
+		err = found(w.m.Collection.FindOne(ctx, filter).Err())
+	case workloadUpdate:
+		_, err = w.m.Collection.UpdateOne(ctx, filter, w.update())
+	case workloadInsert:
+		filter = nil
+		index := w.keys.allocate()
+		_, err = w.m.Collection.InsertOne(ctx, w.record(index))
+		w.keys.acknowledge(index)
+	case workloadScan:
+		length := 1 + w.rng.Int63n(int64(w.opts.MaxScanLength))
+		filter = bson.D{{Key: "_id", Value: bson.D{{Key: "$gte", Value: key}}}}
+		findOpts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}).SetLimit(length)
+		var cursor *mongo.Cursor
+		if cursor, err = w.m.Collection.Find(ctx, filter, findOpts); err == nil {
+			err = drain(ctx, cursor)
+		}
+	case workloadReadModifyWrite:
+		err = w.m.Collection.FindOne(ctx, filter).Err()
+		if err == nil {
+			_, err = w.m.Collection.UpdateOne(ctx, filter, w.update())
+		}
+		err = found(err)
+	}
+	w.m.observe(op, filter, nil, began, err)
+	return op, err
+}
+
+// found treats a missing record as a miss rather than a failure: keys chosen from the keyspace
+// may have been deleted, or never loaded.
+func found(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil
+	}
+	return err
+}
+
+func (w *workload) pick() string {
+	r := w.rng.Float64() * w.total
+	for _, step := range w.steps {
+		if r < step.threshold {
+			return step.op
+		}
+	}
+	return w.steps[len(w.steps)-1].op
+}
+
+// update replaces one random field, as YCSB does by default.
+func (w *workload) update() bson.D {
+	field := fmt.Sprintf("field%d", w.rng.Intn(w.opts.FieldCount))
+	return bson.D{{Key: "$set", Value: bson.D{{Key: field, Value: randomString(w.rng, w.opts.FieldLength)}}}}
+}
+
+func (w *workload) record(index int64) bson.D {
+	doc := make(bson.D, 0, w.opts.FieldCount+1)
+	doc = append(doc, bson.E{Key: "_id", Value: recordKey(index)})
+	for i := 0; i < w.opts.FieldCount; i++ {
+		doc = append(doc, bson.E{Key: fmt.Sprintf("field%d", i), Value: randomString(w.rng, w.opts.FieldLength)})
+	}
+	return doc
+}
+
+func recordKey(index int64) string {
+	return fmt.Sprintf("user%010d", index)
+}
+
+// workloadSource yields the records a workload starts with.
+type workloadSource struct {
+	w     *workload
+	index int64
+}
+
+func (s *workloadSource) next() (interface{}, error) {
+	if s.index >= s.w.opts.RecordCount {
+		return nil, io.EOF
+	}
+	doc := s.w.record(s.index)
+	s.index++
+	return doc, nil
+}